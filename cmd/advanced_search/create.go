@@ -24,23 +24,38 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 		Example: "nerm advsearch create --file search.json",
 		Aliases: []string{"c"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			file := cmd.Flags().Lookup("file").Value.String()
 			prompt := cmd.Flags().Lookup("prompt").Value.String()
 
 			if file != "" {
-				adv_searches := readAdvancedSearchJsonFile(file)
+				adv_searches, readErr := readAdvancedSearchJsonFile(file)
+				if readErr != nil {
+					return readErr
+				}
 
 				formatted, err := json.Marshal(adv_searches.AdvancedSearch)
-				utilities.CheckError(err)
+				if err != nil {
+					return err
+				}
 
 				json_string := "{\"advanced_search\":" + string(formatted) + "}"
 
-				_, requestErr := utilities.MakeAPIRequests("post", "advanced_search", "", "", []byte(json_string))
-				utilities.CheckError(requestErr)
+				_, requestErr := client.Post("advanced_search", "", []byte(json_string))
+				if requestErr != nil {
+					return requestErr
+				}
+				utilities.InvalidateCache(client, utilities.AdvancedSearches)
 
 				fmt.Println("Advanced Search uploaded. New List:")
 
-				newAdvancedSearchListCommand().Execute()
+				listCmd := newAdvancedSearchListCommand()
+				listCmd.SetContext(cmd.Context())
+				return listCmd.RunE(listCmd, nil)
 
 			} else if prompt != "" {
 
@@ -48,17 +63,15 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 
 				advancedSearchLabel := prompt
 
-				types_resp, types_err := utilities.MakeAPIRequests("get", "profile_types", "", "", nil) // get all profile types for later use
-				utilities.CheckError(types_err)
-				var result ProfileTypeResponse
-				unmarshalErr := json.Unmarshal(types_resp, &result)
-				utilities.CheckError(unmarshalErr)
+				profileTypes, typesErr := utilities.ListObjects(cmd.Context(), client, utilities.ProfileTypes) // get all profile types for later use
+				if typesErr != nil {
+					return typesErr
+				}
 
-				riskResp, riskErr := utilities.MakeAPIRequests("get", "risk_levels", "", "", nil) // get all risk Levels for later use
-				utilities.CheckError(riskErr)
-				var riskLevels RiskLevel
-				riskUnmarshalErr := json.Unmarshal(riskResp, &riskLevels)
-				utilities.CheckError(riskUnmarshalErr)
+				riskLevels, riskErr := utilities.ListObjects(cmd.Context(), client, utilities.RiskLevels) // get all risk Levels for later use
+				if riskErr != nil {
+					return riskErr
+				}
 
 				r := bufio.NewReader(os.Stdin)
 
@@ -68,7 +81,9 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 				for {
 					fmt.Fprint(os.Stderr, "What type of Condition Rule do you want to add to the '"+advancedSearchLabel+"' Advanced Searh?:\n1.Profile Type\n2.Profile Status\n3.Profile Attribute\n4.Risk\n5.Exit\n>")
 					conditionRule, readErr := r.ReadString('\n')
-					utilities.CheckError(readErr)
+					if readErr != nil {
+						return readErr
+					}
 
 					conditionRule = strings.TrimSpace(conditionRule)
 
@@ -77,26 +92,32 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 					switch conditionRule {
 					case "1", "Profile Type", "profile type", "type":
 
-						for index, rec := range result.ProfileTypes {
+						for index, rec := range profileTypes {
 							fmt.Println(index+1, ". ", rec.Name)
 						}
 
 						fmt.Println("What Profile Type to add as a Condition Rule? (enter the number)")
 						readType := bufio.NewReader(os.Stdin)
 						profType, readErr := readType.ReadString('\n')
-						utilities.CheckError(readErr)
+						if readErr != nil {
+							return readErr
+						}
 						profType = strings.TrimSpace(profType)
 						i, err := strconv.Atoi(profType)
-						utilities.CheckError(err)
+						if err != nil || i < 1 || i > len(profileTypes) {
+							return utilities.ValidationErrorf("%q is not one of the listed Profile Types", profType)
+						}
 
-						json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\":\"ProfileTypeRule\",\"comparison_operator\":\"==\",\"value\":\"" + result.ProfileTypes[i-1].ID + "\"}]}}"
+						json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\":\"ProfileTypeRule\",\"comparison_operator\":\"==\",\"value\":\"" + profileTypes[i-1].ID + "\"}]}}"
 
 					case "2", "Profile Status", "profile status", "status":
 
 						fmt.Println("What Profile Status to add as a Condition Rule? (Active, Inactive, Terminated, On Leave)")
 						readType := bufio.NewReader(os.Stdin)
 						profStatus, readErr := readType.ReadString('\n')
-						utilities.CheckError(readErr)
+						if readErr != nil {
+							return readErr
+						}
 						statusValue := strings.TrimSpace(profStatus)
 
 						if statusValue != "Active" && statusValue != "Inactive" && statusValue != "Terminated" && statusValue != "On Leave" {
@@ -106,33 +127,38 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 						}
 
 					case "3", "Profile Attribute", "profile attribute", "attribute":
-						fmt.Println("What is the ID of the attribute you want to add to the search?: ")
+						fmt.Println("What attribute do you want to add to the search? (enter its label, UID or ID): ")
 						readID := bufio.NewReader(os.Stdin)
-						attributeId, readErr := readID.ReadString('\n')
-						utilities.CheckError(readErr)
-						attributeId = strings.TrimSpace(attributeId)
+						attributeName, readErr := readID.ReadString('\n')
+						if readErr != nil {
+							return readErr
+						}
 
-						attrResp, attrErr := utilities.MakeAPIRequests("get", "ne_attributes/"+attributeId, "", "", nil)
-						utilities.CheckError(attrErr)
-						var attribute NeAttribute
-						unmarshalErr := json.Unmarshal(attrResp, &attribute)
-						utilities.CheckError(unmarshalErr)
+						attribute, attrErr := utilities.FindAttribute(cmd.Context(), client, attributeName, "")
+						if attrErr != nil {
+							return attrErr
+						}
+						attributeId := attribute.ID
 
-						switch attribute.NeAttribute.Type {
+						switch attribute.Type {
 						case "TextFieldAttribute":
-							fmt.Println("What kind of comparsion do you want to make for the " + attribute.NeAttribute.Label + " value? (==, !=, >, <, start_with?, end_with?, include?): ")
+							fmt.Println("What kind of comparsion do you want to make for the " + attribute.Label + " value? (==, !=, >, <, start_with?, end_with?, include?): ")
 							readCompare := bufio.NewReader(os.Stdin)
 							compareOperator, readErr := readCompare.ReadString('\n')
-							utilities.CheckError(readErr)
+							if readErr != nil {
+								return readErr
+							}
 							compareOperator = strings.TrimSpace(compareOperator)
 
 							if compareOperator != "==" && compareOperator != "!=" && compareOperator != ">" && compareOperator != "<" && compareOperator != "start_with?" && compareOperator != "end_with?" && compareOperator != "include?" {
 								fmt.Println(compareOperator, "is not a valid Comparison Operator. Please enter ==, !=, >, <, start_with?, end_with?, or include?")
 							} else {
-								fmt.Println("What value do you want to look for with " + attribute.NeAttribute.Label + "?: ")
+								fmt.Println("What value do you want to look for with " + attribute.Label + "?: ")
 								readValue := bufio.NewReader(os.Stdin)
 								attributeValue, readErr := readValue.ReadString('\n')
-								utilities.CheckError(readErr)
+								if readErr != nil {
+									return readErr
+								}
 								attributeValue = strings.TrimSpace(attributeValue)
 
 								json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"TextFieldAttribute\",\"condition_object_id\": \"" + attributeId + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + attributeValue + "\"}]}}"
@@ -142,10 +168,12 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 							var compareOperator string
 
 							for {
-								fmt.Println("What kind of comparsion do you want to make for the " + attribute.NeAttribute.Label + " value? (>, <, before, after, ==): ")
+								fmt.Println("What kind of comparsion do you want to make for the " + attribute.Label + " value? (>, <, before, after, ==): ")
 								readCompare := bufio.NewReader(os.Stdin)
 								compareOperatorRead, readErr := readCompare.ReadString('\n')
-								utilities.CheckError(readErr)
+								if readErr != nil {
+									return readErr
+								}
 								compareOperator = strings.TrimSpace(compareOperatorRead)
 
 								if compareOperator != ">" && compareOperator != "<" && compareOperator != "after" && compareOperator != "before" && compareOperator != "==" {
@@ -161,7 +189,9 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 									fmt.Println("Do you want to compare against 'Today', a date, or another attribute? (enter 'attribute' to compare against another attribute)")
 									readValue := bufio.NewReader(os.Stdin)
 									compareValue, readErr := readValue.ReadString('\n')
-									utilities.CheckError(readErr)
+									if readErr != nil {
+										return readErr
+									}
 									compareValue = strings.TrimSpace(compareValue)
 
 									if compareValue != "Today" && compareValue != "today" && compareValue != "attribute" && compareValue != "Attribute" && compareValue != "date" && compareValue != "Dttribute" {
@@ -171,17 +201,18 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 
 									if compareValue == "attribute" {
 										for {
-											fmt.Println("Enter the Attribute ID for the attribute you want to compare " + attribute.NeAttribute.Label + " with:")
+											fmt.Println("Enter the label, UID or ID of the attribute you want to compare " + attribute.Label + " with:")
 											readAttributeIdValue := bufio.NewReader(os.Stdin)
 											attrIDValue, readErr := readAttributeIdValue.ReadString('\n')
-											utilities.CheckError(readErr)
-											attrIDValue = strings.TrimSpace(attrIDValue)
+											if readErr != nil {
+												return readErr
+											}
 
-											attrResp, attrErr := utilities.MakeAPIRequests("get", "ne_attributes/"+attrIDValue, "", "", nil)
-											utilities.CheckError(attrErr)
+											secondaryAttribute, attrErr := utilities.FindAttribute(cmd.Context(), client, attrIDValue, "")
+											attrIDValue = secondaryAttribute.ID
 
 											if attrErr != nil { // if there is an error
-												fmt.Println("There was an issue with that attribute. Please enter a valid attribute ID.\n\n", attrResp)
+												fmt.Println("There was an issue with that attribute. Please enter a valid attribute.\n\n", attrErr)
 											} else {
 												json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"DateAttribute\",\"condition_object_id\": \"" + attributeId + "\",\"secondary_attribute_type\": \"DateAttribute\",\"secondary_attribute_id\": \"" + attrIDValue + "\",\"comparison_operator\": \"" + compareOperator + "\"}]}}"
 												break compareLoop
@@ -195,9 +226,8 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 									} else if compareValue == "date" { // must be a date value
 										// try to parse for a date, used in a later check
 										_, timeErr := time.Parse("01/02/2006", compareValue)
-										utilities.CheckError(timeErr)
 										if timeErr != nil {
-											fmt.Println("Please enter attribute, 'Today', or a valid date string")
+											return utilities.ValidationErrorf("please enter attribute, 'Today', or a valid date string")
 										}
 										json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"DateAttribute\",\"condition_object_id\": \"" + attributeId + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + compareValue + "\"}]}}"
 										break
@@ -211,7 +241,9 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 									fmt.Println("Do you want to compare against 'Today', a date, or another attribute? (enter 'attribute' to compare against another attribute)")
 									readValue := bufio.NewReader(os.Stdin)
 									compareValue, readErr := readValue.ReadString('\n')
-									utilities.CheckError(readErr)
+									if readErr != nil {
+										return readErr
+									}
 									compareValue = strings.TrimSpace(compareValue)
 
 									if compareValue != "Today" && compareValue != "today" && compareValue != "attribute" && compareValue != "Attribute" && compareValue != "date" && compareValue != "Dttribute" {
@@ -224,11 +256,12 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 											fmt.Println("Please enter a date in mm/dd/yyyy format:")
 											readdateValue := bufio.NewReader(os.Stdin)
 											dateValue, readErr := readdateValue.ReadString('\n')
-											utilities.CheckError(readErr)
+											if readErr != nil {
+												return readErr
+											}
 											dateValue = strings.TrimSpace(dateValue)
 
 											_, timeErr := time.Parse("01/02/2006", dateValue)
-											utilities.CheckError(timeErr)
 
 											if timeErr != nil {
 												fmt.Println("Please enter a valid date.")
@@ -241,31 +274,33 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 
 									if compareValue == "attribute" {
 										for {
-											fmt.Println("Enter the Attribute ID for the attribute you want to compare " + attribute.NeAttribute.Label + " with:")
+											fmt.Println("Enter the label, UID or ID of the attribute you want to compare " + attribute.Label + " with:")
 											readAttributeIdValue := bufio.NewReader(os.Stdin)
 											attrIDValue, readErr := readAttributeIdValue.ReadString('\n')
-											utilities.CheckError(readErr)
-											attrIDValue = strings.TrimSpace(attrIDValue)
+											if readErr != nil {
+												return readErr
+											}
 
-											attrResp, attrErr := utilities.MakeAPIRequests("get", "ne_attributes/"+attrIDValue, "", "", nil)
-											utilities.CheckError(attrErr)
-											var secondaryAttribute NeAttribute
-											unmarshalErr := json.Unmarshal(attrResp, &secondaryAttribute)
-											utilities.CheckError(unmarshalErr)
+											secondaryAttribute, attrErr := utilities.FindAttribute(cmd.Context(), client, attrIDValue, "")
+											attrIDValue = secondaryAttribute.ID
 
 											if attrErr != nil { // if there is an error
-												fmt.Println("There was an issue with that attribute. Please enter a valid attribute ID.\n\n", attrResp)
+												fmt.Println("There was an issue with that attribute. Please enter a valid attribute.\n\n", attrErr)
 											} else {
-												fmt.Println("Do you want to check for a number of days 'before' or 'after' " + secondaryAttribute.NeAttribute.Label + "?")
+												fmt.Println("Do you want to check for a number of days 'before' or 'after' " + secondaryAttribute.Label + "?")
 												readBeforeOrAfterValue := bufio.NewReader(os.Stdin)
 												compareBeforeOrAfterValue, readErr := readBeforeOrAfterValue.ReadString('\n')
-												utilities.CheckError(readErr)
+												if readErr != nil {
+													return readErr
+												}
 												compareBeforeOrAfterValue = strings.TrimSpace(compareBeforeOrAfterValue)
 
-												fmt.Println("How many days " + compareBeforeOrAfterValue + " " + secondaryAttribute.NeAttribute.Label + " do you want to look for?")
+												fmt.Println("How many days " + compareBeforeOrAfterValue + " " + secondaryAttribute.Label + " do you want to look for?")
 												readNumDaysValue := bufio.NewReader(os.Stdin)
 												numDays, readErr := readNumDaysValue.ReadString('\n')
-												utilities.CheckError(readErr)
+												if readErr != nil {
+													return readErr
+												}
 
 												if compareBeforeOrAfterValue == "before" || compareBeforeOrAfterValue == "after" {
 													json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"DateAttribute\",\"condition_object_id\": \"" + attributeId + "\",\"secondary_attribute_type\":\"DateAttribute\",\"secondary_attribute_id\":\"" + attrIDValue + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + compareValue + "\",\"secondary_value\":\"" + compareBeforeOrAfterValue + "\",\"tertiary_value\":" + numDays + "}]}}"
@@ -280,13 +315,17 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 										fmt.Println("Do you want to check for a number of days 'before' or 'after' " + compareValue + "?")
 										readBeforeOrAfterValue := bufio.NewReader(os.Stdin)
 										compareBeforeOrAfterValue, readErr := readBeforeOrAfterValue.ReadString('\n')
-										utilities.CheckError(readErr)
+										if readErr != nil {
+											return readErr
+										}
 										compareBeforeOrAfterValue = strings.TrimSpace(compareBeforeOrAfterValue)
 
 										fmt.Println("How many days " + compareBeforeOrAfterValue + " " + compareValue + " do you want to look for?")
 										readNumDaysValue := bufio.NewReader(os.Stdin)
 										numDays, readErr := readNumDaysValue.ReadString('\n')
-										utilities.CheckError(readErr)
+										if readErr != nil {
+											return readErr
+										}
 
 										if compareBeforeOrAfterValue == "before" || compareBeforeOrAfterValue == "after" {
 											json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"DateAttribute\",\"condition_object_id\": \"" + attributeId + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + compareValue + "\",\"secondary_value\":\"" + compareBeforeOrAfterValue + "\",\"tertiary_value\":" + numDays + "}]}}"
@@ -298,56 +337,71 @@ func newAdvancedSearchCreateCommand() *cobra.Command {
 								}
 							}
 						case "ProfileSelectAttribute", "ProfileSearchAttribute", "OwnerSelectAttribute", "OwnerSearchAttribute", "ContributorSelectAttribute", "ContributorSearchAttribute":
-							fmt.Println("What kind of comparsion do you want to make for the " + attribute.NeAttribute.Label + " value? (include?, exclude?): ")
+							fmt.Println("What kind of comparsion do you want to make for the " + attribute.Label + " value? (include?, exclude?): ")
 							readCompare := bufio.NewReader(os.Stdin)
 							compareOperator, readErr := readCompare.ReadString('\n')
-							utilities.CheckError(readErr)
+							if readErr != nil {
+								return readErr
+							}
 							compareOperator = strings.TrimSpace(compareOperator)
 
 							if compareOperator != "include?" && compareOperator != "exclude?" {
 								fmt.Println(compareOperator, "is not a valid Comparison Operator. Please enter include? or exclude?")
 							} else {
-								fmt.Println("What value do you want to look for with " + attribute.NeAttribute.Label + "? (enter the ID of the Profile / User)")
+								fmt.Println("What value do you want to look for with " + attribute.Label + "? (enter the ID of the Profile / User)")
 								readValue := bufio.NewReader(os.Stdin)
 								attributeValue, readErr := readValue.ReadString('\n')
-								utilities.CheckError(readErr)
+								if readErr != nil {
+									return readErr
+								}
 								attributeValue = strings.TrimSpace(attributeValue)
 
-								json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"" + attribute.NeAttribute.Type + "\",\"condition_object_id\": \"" + attributeId + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + attributeValue + "\"}]}}"
+								json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\": \"ProfileAttributeRule\",\"condition_object_type\": \"" + attribute.Type + "\",\"condition_object_id\": \"" + attributeId + "\",\"comparison_operator\": \"" + compareOperator + "\",\"value\": \"" + attributeValue + "\"}]}}"
 							}
 						}
 
 					case "4", "Risk", "risk":
 						fmt.Println("Risk Rules are based on the 'Risk Level' that a Profile has. Which Risk Level do you want to search for? (enter the number)")
-						for index, rec := range riskLevels.RiskLevels {
+						for index, rec := range riskLevels {
 							fmt.Println(index+1, ". ", rec.Label)
 						}
 
 						readType := bufio.NewReader(os.Stdin)
 						risk, readErr := readType.ReadString('\n')
-						utilities.CheckError(readErr)
+						if readErr != nil {
+							return readErr
+						}
 						risk = strings.TrimSpace(risk)
 						i, err := strconv.Atoi(risk)
-						utilities.CheckError(err)
+						if err != nil || i < 1 || i > len(riskLevels) {
+							return utilities.ValidationErrorf("%q is not one of the listed Risk Levels", risk)
+						}
 
-						json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\":\"RiskRule\",\"comparison_operator\":\"==\",\"value\":\"" + riskLevels.RiskLevels[i-1].ID + "\"}]}}"
+						json_string = "{\"advanced_search\": {\"condition_rules_attributes\": [{\"type\":\"RiskRule\",\"comparison_operator\":\"==\",\"value\":\"" + riskLevels[i-1].ID + "\"}]}}"
 
 					case "5", "Exit", "exit", "Quit", "quit", "e", "q":
 						break outer
 					}
 
 					if firstLoop {
-						resp, requestErr := utilities.MakeAPIRequests("post", "advanced_search", "", "", []byte("{\"advanced_search\": {\"label\": \""+advancedSearchLabel+"\"}}"))
-						utilities.CheckError(requestErr)
+						resp, requestErr := client.Post("advanced_search", "", []byte("{\"advanced_search\": {\"label\": \""+advancedSearchLabel+"\"}}"))
+						if requestErr != nil {
+							return requestErr
+						}
+						utilities.InvalidateCache(client, utilities.AdvancedSearches)
 
-						unmarshalErr = json.Unmarshal(resp, &adv_search)
-						utilities.CheckError(unmarshalErr)
+						unmarshalErr := json.Unmarshal(resp, &adv_search)
+						if unmarshalErr != nil {
+							return unmarshalErr
+						}
 
 						firstLoop = false
 						json_string = "" // reset string
 					}
-					_, requestErr := utilities.MakeAPIRequests("patch", "advanced_search/"+adv_search.AdvancedSearch.ID, "", "", []byte(json_string))
-					utilities.CheckError(requestErr)
+					_, requestErr := client.Patch("advanced_search", adv_search.AdvancedSearch.ID, []byte(json_string))
+					if requestErr != nil {
+						return requestErr
+					}
 					json_string = "" // reset string
 
 				}
@@ -4,12 +4,8 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package advanced_search
 
 import (
-	"encoding/json"
-	"math"
 	"nerm/cmd/utilities"
-	"net/url"
 	"slices"
-	"strconv"
 
 	"github.com/spf13/cobra"
 )
@@ -23,49 +19,30 @@ func newAdvancedSearchListCommand() *cobra.Command {
 		Aliases: []string{"l"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			limitInt := 100
-			getLimitInt := math.MaxInt32
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
 
-			var resp []byte
-			var requestErr error
-			var respMetaData ResponseMetaData
 			var finalAdvSearches []string
 
-			params := url.Values{}
-			params.Add("metadata", "true") //  include metadata for limit/offsets
-			params.Add("limit", "1")       // Get 1 to see how many there are total
-			params.Add("offset", "0")
-
-			resp, requestErr = utilities.MakeAPIRequests("get", "advanced_search", "", params.Encode(), nil)
-			utilities.CheckError(requestErr)
-			err := json.Unmarshal(resp, &respMetaData)
-			utilities.CheckError(err)
+			pager := utilities.NewPaginator[AdvancedSearchData](client, "advanced_search", "advanced_search", nil)
 
-			if getLimitInt > respMetaData.Metadata.Total {
-				getLimitInt = respMetaData.Metadata.Total
+			total, err := pager.Total(cmd.Context()) // Get 1 to see how many there are total
+			if err != nil {
+				return err
 			}
+			pager.GetLimit = total
 
-			params.Set("limit", "100")
-
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
-				var adv_searches AdvancedSearchConfig
-
-				params.Set("offset", strconv.Itoa(offset))
-
-				resp, requestErr = utilities.MakeAPIRequests("get", "advanced_search", "", params.Encode(), nil)
-				utilities.CheckError(requestErr)
-
-				err = json.Unmarshal(resp, &adv_searches)
-				utilities.CheckError(err)
-
-				for _, rec := range adv_searches.AdvancedSearch {
-					// var row []string
-					// row = append(row, rec.Label)
-					// row = append(row, rec.ID)
-					finalAdvSearches = append(finalAdvSearches, rec.Label+"|"+rec.ID)
-				}
+			adv_searches, err := pager.All(cmd.Context())
+			if err != nil {
+				return err
+			}
 
+			for _, rec := range adv_searches {
+				finalAdvSearches = append(finalAdvSearches, rec.Label+"|"+rec.ID)
 			}
+
 			slices.Sort(finalAdvSearches)
 			printAdvSearchListTable(finalAdvSearches)
 
@@ -4,7 +4,7 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package advanced_search
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"math"
 	"nerm/cmd/configs"
@@ -21,11 +21,16 @@ func newAdvancedSearchRunCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "run",
 		Short:   "Pulls Profiles from current environment",
-		Long:    "Pulls Profiles from current environment based on an advanced Search. Stores data in a CSV and JSON file at the defaul output location",
+		Long:    "Pulls Profiles from current environment based on an advanced Search. Stores data at the defaul output location in the formats set with --format (JSON and CSV by default)",
 		Example: "nerm advsearch run --id 123",
 		Aliases: []string{"r"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_AdvancedSearch_Export" + strconv.Itoa(int(time.Now().Unix()))
 
 			params := url.Values{}
@@ -35,70 +40,84 @@ func newAdvancedSearchRunCommand() *cobra.Command {
 			getLimit := cmd.Flags().Lookup("get_limit").Value.String()
 
 			limitInt, err := strconv.Atoi(limit)
-			utilities.CheckError(err)
+			if err != nil {
+				return utilities.ValidationErrorf("%q is not a valid number for --limit", limit)
+			}
+
+			id, err = utilities.ResolveID(cmd.Context(), client, utilities.AdvancedSearches, id)
+			if err != nil {
+				return err
+			}
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
 
 			if limitInt > 100 {
 				fmt.Println("Limit can not be over 100 - Setting it back down to 100")
-				params.Add("limit", "100")
-			} else {
-				params.Add("limit", limit)
+				limitInt = 100
 			}
-			params.Add("offset", "0")
 
 			var getLimitInt int
 
 			if getLimit != "" {
 				getLimitInt, err = strconv.Atoi(getLimit)
-				utilities.CheckError(err)
+				if err != nil {
+					return utilities.ValidationErrorf("%q is not a valid number for --get_limit", getLimit)
+				}
 			} else {
 				getLimitInt = math.MaxInt
 			}
 
-			var resp []byte
-			var requestErr error
-
-			createAdvancedSearchJsonFile(outputLoc + ".json")
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), profileTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
 
 			bar := progressbar.Default(-1, "Getting Profiles...")
 
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
-
-				params.Set("offset", strconv.Itoa(offset))
-
-				resp, requestErr = utilities.RunAdvSearchRequest(id, params.Encode())
-				utilities.CheckError(requestErr)
-
-				var advSearch_result ProfileResponse
-
-				err := json.Unmarshal(resp, &advSearch_result)
-				utilities.CheckError(err)
-
-				// check to compensate for advanced search returning a 200 with empty body
-				// when no profiles are found.. If there are profiles, prep file
-				if len(advSearch_result.Profiles) == 0 {
-					break
-				} else if offset != 0 { //not first loop, so dont add comma to json file
-					addCommaToFile(outputLoc + ".json")
+			// the run endpoint does not return a total, so pages are fetched until one comes back empty
+			pager := utilities.NewPaginator[ProfileJsonFileData](client, "advanced_search", "advanced_search", params)
+			pager.Fetch = func(ctx context.Context, params string) ([]byte, error) {
+				return client.WithContext(ctx).RunAdvancedSearch(id, params)
+			}
+			pager.Limit = limitInt
+			pager.GetLimit = getLimitInt
+			pager.Concurrency = concurrency
+
+			err = pager.Pages(cmd.Context(), func(page utilities.Page[ProfileJsonFileData]) error {
+				for _, rec := range page.Records {
+					if err := output.Write(rec); err != nil {
+						return err
+					}
 				}
 
-				printJsonToFile(outputLoc+".json", advSearch_result)
-
-				bar.Add(len(advSearch_result.Profiles))
+				bar.Add(len(page.Records))
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
-			endAdvancedSearchJsonFile(outputLoc + ".json")
-
-			convertJSONToCSV(outputLoc+".json", outputLoc+".csv")
+			if err := output.Finish(); err != nil {
+				return err
+			}
 
 			fmt.Println("\n\n\n" + "Profile data stored in " + outputLoc)
 
 			return nil
 		},
 	}
-	cmd.Flags().StringP("id", "i", "", "ID of a specific advanced Search")
+	cmd.Flags().StringP("id", "i", "", "A specific Advanced Search (ID or label)")
 	cmd.Flags().StringP("limit", "l", strconv.Itoa(configs.GetDefaultLimitParam()), "Limit for each GET request")
 	cmd.Flags().StringP("get_limit", "g", "", "Set a Get limit for how many profiles to pull back (default is All profiles)")
 
+	cmd.RegisterFlagCompletionFunc("id", utilities.CompleteNames(utilities.AdvancedSearches))
+
+	cmd.Flags().String("concurrency", "1", "Number of pages to fetch at the same time")
+
 	cmd.MarkFlagRequired("id")
 
 	return cmd
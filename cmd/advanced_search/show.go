@@ -20,25 +20,43 @@ func newAdvancedSearchShowCommand() *cobra.Command {
 		Example: "nerm advsearch show --id 1234",
 		Aliases: []string{"s"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			id := cmd.Flags().Lookup("id").Value.String()
+			id, resolveErr := utilities.ResolveID(cmd.Context(), client, utilities.AdvancedSearches, id)
+			if resolveErr != nil {
+				return resolveErr
+			}
 			var adv_searches AdvancedSearchConfig
 
 			params := url.Values{}
 			params.Add("id", id)
 
-			resp, requestErr := utilities.MakeAPIRequests("get", "advanced_search", "", params.Encode(), nil)
-			utilities.CheckError(requestErr)
+			resp, requestErr := client.Get("advanced_search", "", params.Encode())
+			if requestErr != nil {
+				return requestErr
+			}
 			err := json.Unmarshal(resp, &adv_searches)
-			utilities.CheckError(err)
+			if err != nil {
+				return err
+			}
 
 			formatted, err := json.MarshalIndent(adv_searches.AdvancedSearch, "", "  ")
-			utilities.CheckError(err)
+			if err != nil {
+				return err
+			}
 			fmt.Println(string(formatted))
 
 			return nil
 		},
 	}
-	cmd.Flags().StringP("id", "i", "", "ID of a specific Advanced Search")
+	cmd.Flags().StringP("id", "i", "", "A specific Advanced Search (ID or label)")
+
+	cmd.RegisterFlagCompletionFunc("id", utilities.CompleteNames(utilities.AdvancedSearches))
+
 	cmd.MarkFlagRequired("id")
 
 	return cmd
@@ -23,26 +23,44 @@ func newAdvancedSearchDownloadCommand() *cobra.Command {
 		Example: "nerm advsearch download --id 1234",
 		Aliases: []string{"s"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			id := cmd.Flags().Lookup("id").Value.String()
+			id, resolveErr := utilities.ResolveID(cmd.Context(), client, utilities.AdvancedSearches, id)
+			if resolveErr != nil {
+				return resolveErr
+			}
 			var adv_searches AdvancedSearchConfigForDownload
 
 			params := url.Values{}
 			params.Add("id", id)
 
-			resp, requestErr := utilities.MakeAPIRequests("get", "advanced_search", "", params.Encode(), nil)
-			utilities.CheckError(requestErr)
+			resp, requestErr := client.Get("advanced_search", "", params.Encode())
+			if requestErr != nil {
+				return requestErr
+			}
 			err := json.Unmarshal(resp, &adv_searches)
-			utilities.CheckError(err)
+			if err != nil {
+				return err
+			}
 
 			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_" + adv_searches.AdvancedSearch[0].Label + "_AdvancedSearch_Config" + strconv.Itoa(int(time.Now().Unix()))
-			storeAdvancedSearchJsonFile(outputLoc+".json", adv_searches)
+			if err := storeAdvancedSearchJsonFile(outputLoc+".json", adv_searches); err != nil {
+				return err
+			}
 
 			fmt.Println("\n" + "Advanced Saerch config data stored in " + outputLoc + ".json")
 
 			return nil
 		},
 	}
-	cmd.Flags().StringP("id", "i", "", "ID of a specific Advanced Search")
+	cmd.Flags().StringP("id", "i", "", "A specific Advanced Search (ID or label)")
+
+	cmd.RegisterFlagCompletionFunc("id", utilities.CompleteNames(utilities.AdvancedSearches))
+
 	cmd.MarkFlagRequired("id")
 
 	return cmd
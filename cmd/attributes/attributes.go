@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package attributes
+
+import (
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+func NewAttributesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "attributes",
+		Short:   "List and search Profile Attributes",
+		Long:    "List, search and show the attributes (ne_attributes) of the profile types in an environment, to find the IDs other commands need",
+		Example: "nerm attributes list --search email | nerm attributes show \"Start Date\"",
+		Aliases: []string{"attr"},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newAttributesListCommand(),
+		newAttributesShowCommand(),
+	)
+
+	return cmd
+}
+
+func printAttributeListTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Label", "UID", "Type", "Profile Type", "ID")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4])
+	}
+
+	tbl.Print()
+}
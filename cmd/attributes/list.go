@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package attributes
+
+import (
+	"fmt"
+	"nerm/cmd/utilities"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newAttributesListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "Lists the Attributes of an environment",
+		Long:    "Lists the attributes of every profile type, or of one profile type. Filter by attribute type (ie DateAttribute) or search the labels and UIDs. Archived attributes are left out unless --archived is set",
+		Example: "nerm attributes list | nerm attributes list --profile_type 1234abcd-1234-abcd-5678-12345abcd5678 --type DateAttribute | nerm attributes list --search email",
+		Aliases: []string{"l"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			profile_type := cmd.Flags().Lookup("profile_type").Value.String()
+			attributeType := cmd.Flags().Lookup("type").Value.String()
+			search := strings.ToLower(cmd.Flags().Lookup("search").Value.String())
+			archived, _ := cmd.Flags().GetBool("archived")
+
+			profile_type, err := utilities.ResolveID(cmd.Context(), client, utilities.ProfileTypes, profile_type)
+			if err != nil {
+				return err
+			}
+
+			attributes, err := utilities.ListAttributes(cmd.Context(), client, profile_type)
+			if err != nil {
+				return err
+			}
+
+			profileTypes, err := utilities.ObjectNames(cmd.Context(), client, utilities.ProfileTypes)
+			if err != nil {
+				return err
+			}
+
+			var finalValues [][]string
+			for _, attribute := range attributes {
+				if attribute.Archived && !archived {
+					continue
+				}
+				if attributeType != "" && !strings.EqualFold(attribute.Type, attributeType) {
+					continue
+				}
+				if search != "" && !strings.Contains(strings.ToLower(attribute.Label), search) && !strings.Contains(strings.ToLower(attribute.UID), search) {
+					continue
+				}
+
+				label := attribute.Label
+				if attribute.Archived {
+					label += " (archived)"
+				}
+				finalValues = append(finalValues, []string{label, attribute.UID, attribute.Type, profileTypes[attribute.ProfileTypeID], attribute.ID})
+			}
+
+			if len(finalValues) == 0 {
+				fmt.Println("No attributes found")
+				return nil
+			}
+
+			// sorted by profile type, then label
+			slices.SortFunc(finalValues, func(a, b []string) int {
+				if c := strings.Compare(a[3], b[3]); c != 0 {
+					return c
+				}
+				return strings.Compare(strings.ToLower(a[0]), strings.ToLower(b[0]))
+			})
+
+			printAttributeListTable(finalValues)
+			fmt.Println("\n"+fmt.Sprint(len(finalValues)), "attribute(s)")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("profile_type", "t", "", "Only list the attributes of this profile type (ID, UID or name)")
+	cmd.Flags().String("type", "", "Only list attributes of this type (ie TextFieldAttribute, DateAttribute, ProfileSelectAttribute)")
+	cmd.Flags().StringP("search", "s", "", "Only list attributes whose label or UID contains this text")
+	cmd.Flags().Bool("archived", false, "Include archived attributes")
+
+	cmd.RegisterFlagCompletionFunc("profile_type", utilities.CompleteNames(utilities.ProfileTypes))
+
+	return cmd
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package attributes
+
+import (
+	"encoding/json"
+	"fmt"
+	"nerm/cmd/utilities"
+
+	"github.com/spf13/cobra"
+)
+
+func newAttributesShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "show <id|uid|label>",
+		Short:   "Shows the configuration of an Attribute",
+		Long:    "Shows the full configuration of an attribute, found by its ID, UID or label. When a label is used by more than one attribute, each of them is listed so the right ID can be picked",
+		Example: "nerm attributes show \"Start Date\" | nerm attributes show start_date --profile_type 1234abcd-1234-abcd-5678-12345abcd5678",
+		Aliases: []string{"s"},
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			profile_type := cmd.Flags().Lookup("profile_type").Value.String()
+
+			profile_type, err := utilities.ResolveID(cmd.Context(), client, utilities.ProfileTypes, profile_type)
+			if err != nil {
+				return err
+			}
+
+			attribute, err := utilities.FindAttribute(cmd.Context(), client, args[0], profile_type)
+			if err != nil {
+				return err
+			}
+
+			formatted, err := json.MarshalIndent(attribute, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(formatted))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("profile_type", "t", "", "Only look at the attributes of this profile type (ID, UID or name)")
+
+	cmd.RegisterFlagCompletionFunc("profile_type", utilities.CompleteNames(utilities.ProfileTypes))
+
+	return cmd
+}
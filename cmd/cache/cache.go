@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package cache
+
+import (
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+func NewCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "cache",
+		Short:   "Manage the metadata cache",
+		Long:    "Profile types, attributes, workflows, advanced searches and risk levels are cached in the .nerm/cache folder of your User directory, so name lookups and shell completion are fast and work offline. The cache is pulled again once it is older than cache_ttl in the config file",
+		Example: "nerm cache show | nerm cache refresh | nerm cache clear --all_envs",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newCacheRefreshCommand(),
+		newCacheClearCommand(),
+		newCacheShowCommand(),
+	)
+
+	return cmd
+}
+
+func printCacheTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Environment", "List", "Records", "Fetched At", "Status")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4])
+	}
+
+	tbl.Print()
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package cache
+
+import (
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+
+	"github.com/spf13/cobra"
+)
+
+func newCacheClearCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "clear",
+		Short:   "Removes the metadata cache",
+		Long:    "Removes the metadata cache of the current environment, or of every environment with --all_envs. It is pulled again the next time it is needed",
+		Example: "nerm cache clear | nerm cache clear --all_envs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allEnvs, _ := cmd.Flags().GetBool("all_envs")
+
+			environment := configs.GetCurrentEnvironment()
+			if allEnvs {
+				environment = ""
+			} else if environment == "" {
+				return utilities.ValidationErrorf("no environment is in use. Use --all_envs to clear the cache of every environment")
+			}
+
+			if err := utilities.ClearCache(environment); err != nil {
+				return err
+			}
+
+			if allEnvs {
+				fmt.Println("Cache cleared for all environments")
+			} else {
+				fmt.Println("Cache cleared for " + environment)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolP("all_envs", "a", false, "Clear the cache of every environment. Else, just the current one")
+
+	return cmd
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package cache
+
+import (
+	"fmt"
+	"nerm/cmd/utilities"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newCacheRefreshCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "refresh",
+		Short:   "Pulls the metadata cache again",
+		Long:    "Pulls every cached list of the current environment again, whatever its age",
+		Example: "nerm cache refresh",
+		Aliases: []string{"r"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			entries, err := utilities.RefreshCache(cmd.Context(), client)
+			for _, entry := range entries {
+				fmt.Println(entry.Kind.Endpoint+":", strconv.Itoa(entry.Records), "record(s)")
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("\n" + "Cache of " + client.Environment + " refreshed at " + time.Now().Format(time.DateTime))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package cache
+
+import (
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newCacheShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "show",
+		Short:   "Shows what is in the metadata cache",
+		Long:    "Shows each cached list of the current environment (or of every environment with --all_envs), how many records it holds, when it was pulled and whether it is older than cache_ttl",
+		Example: "nerm cache show | nerm cache show --all_envs",
+		Aliases: []string{"s"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allEnvs, _ := cmd.Flags().GetBool("all_envs")
+
+			environments := []string{strings.ToLower(configs.GetCurrentEnvironment())}
+			if allEnvs {
+				environments = nil
+				for environment := range configs.GetAllEnvironments() {
+					environments = append(environments, environment)
+				}
+				slices.Sort(environments)
+			}
+
+			ttl := configs.GetCacheTTL()
+
+			var finalValues [][]string
+			for _, environment := range environments {
+				for _, entry := range utilities.CacheEntries(environment) {
+					status := "fresh"
+					if time.Since(entry.FetchedAt) >= ttl {
+						status = "stale"
+					}
+					finalValues = append(finalValues, []string{environment, entry.Kind.Endpoint, strconv.Itoa(entry.Records), entry.FetchedAt.Local().Format(time.DateTime), status})
+				}
+			}
+
+			if len(finalValues) == 0 {
+				fmt.Println("Nothing is cached yet. Run 'nerm cache refresh' to fill the cache")
+				return nil
+			}
+
+			printCacheTable(finalValues)
+			fmt.Println("\n" + "Cache folder: " + configs.GetCacheFolder() + ", TTL: " + ttl.String())
+			if !configs.GetCacheEnabled() {
+				fmt.Println("The cache is turned off for this run (--no-cache)")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolP("all_envs", "a", false, "Show the cache of every environment. Else, just the current one")
+
+	return cmd
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/zalando/go-keyring"
@@ -19,6 +20,9 @@ const (
 	configEnvFile = "nerm_config.yaml"
 )
 
+// overrides hold values set by global flags. They only apply to the current run and are never saved to the config file
+var overrides = map[string]interface{}{}
+
 type Environment struct {
 	Tenant   string `mapstructure:"tenant"`
 	APIToken string `mapstructure:"token"`
@@ -40,6 +44,11 @@ func InitConfig() error {
 	viper.SetDefault("DEFAULT_OUTPUT_LOCATION", "")
 	viper.SetDefault("LIMIT", 100)
 	viper.SetDefault("CURRENT_ENVIRONMENT", "")
+	viper.SetDefault("MAX_RETRIES", 3)
+	viper.SetDefault("REQUESTS_PER_SECOND", 10)
+	viper.SetDefault("BURST", 10)
+	viper.SetDefault("FORMAT", []string{"json", "csv"})
+	viper.SetDefault("CACHE_TTL", "24h")
 
 	viper.AutomaticEnv()
 
@@ -90,12 +99,9 @@ func SetTenant(tenant string) {
 func SetBaseURL(baseurl string) {
 	viper.Set("ALL_ENVIRONMENTS."+GetCurrentEnvironment()+".BASEURL", baseurl)
 }
-func SetAPIToken(service string, user string, pass string){
+func SetAPIToken(service string, user string, pass string) error {
 	// set password
-    err := keyring.Set(service,user,"Bearer "+pass)
-    if err != nil {
-        log.Fatal(err)
-    }
+	return keyring.Set(service, user, "Bearer "+pass)
 }
 
 func SetOutputFolder(default_output_location string) {
@@ -104,38 +110,104 @@ func SetOutputFolder(default_output_location string) {
 func SetDefaultLimitParam(limit string) {
 	viper.Set("LIMIT", limit)
 }
+func SetMaxRetries(maxRetries int) {
+	viper.Set("MAX_RETRIES", maxRetries)
+}
+func SetOutputFormats(formats []string) {
+	viper.Set("FORMAT", formats)
+}
+func SetCacheTTL(ttl string) {
+	viper.Set("CACHE_TTL", ttl)
+}
 
+// SetOverride replaces a setting for the current run only (ie from a global flag)
+func SetOverride(key string, value interface{}) {
+	overrides[strings.ToUpper(key)] = value
+}
 
 func GetCurrentEnvironment() string {
 	return strings.ToLower(viper.GetString("CURRENT_ENVIRONMENT"))
 }
 func GetTenant() string {
-	return viper.GetString("ALL_ENVIRONMENTS." + GetCurrentEnvironment() + ".TENANT")
+	return GetTenantFor(GetCurrentEnvironment())
+}
+func GetTenantFor(environment string) string {
+	return viper.GetString("ALL_ENVIRONMENTS." + strings.ToLower(environment) + ".TENANT")
 }
 func GetBaseURL() string {
-	base := viper.GetString("ALL_ENVIRONMENTS." + GetCurrentEnvironment() + ".BASEURL")
+	return GetBaseURLFor(GetCurrentEnvironment())
+}
+func GetBaseURLFor(environment string) string {
+	base := viper.GetString("ALL_ENVIRONMENTS." + strings.ToLower(environment) + ".BASEURL")
 	if base == "" {
 		base = viper.GetString("BASEURL")
 	}
 	return base
 }
-func GetAPIToken() string {
-	tenant := GetTenant()
-	service := tenant+"."+GetBaseURL()
-	token, err := keyring.Get(service, tenant)
-	if err != nil {
-        log.Fatal(err)
-    }
+func GetRateLimitFor(environment string) (float64, int) {
+	key := "ALL_ENVIRONMENTS." + strings.ToLower(environment)
 
-	return token
+	requestsPerSecond := viper.GetFloat64("REQUESTS_PER_SECOND")
+	if viper.IsSet(key + ".REQUESTS_PER_SECOND") {
+		requestsPerSecond = viper.GetFloat64(key + ".REQUESTS_PER_SECOND")
+	}
+	burst := viper.GetInt("BURST")
+	if viper.IsSet(key + ".BURST") {
+		burst = viper.GetInt(key + ".BURST")
+	}
+	return requestsPerSecond, burst
+}
+func GetAPIToken() (string, error) {
+	return GetAPITokenFor(GetTenant(), GetBaseURL())
 	// return viper.GetString("ALL_ENVIRONMENTS." + GetCurrentEnvironment() + ".TOKEN")
 }
+func GetAPITokenFor(tenant string, baseurl string) (string, error) {
+	return keyring.Get(tenant+"."+baseurl, tenant)
+}
 func GetOutputFolder() string {
 	return viper.GetString("DEFAULT_OUTPUT_LOCATION")
 }
 func GetDefaultLimitParam() int {
 	return viper.GetInt("LIMIT")
 }
+func GetOutputFormats() []string {
+	if value, ok := overrides["FORMAT"].([]string); ok {
+		return value
+	}
+	return viper.GetStringSlice("FORMAT")
+}
+
+// GetCacheFolder is where the metadata cache is kept. Each environment has its own folder in it
+func GetCacheFolder() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, configFolder, "cache")
+}
+
+// GetCacheTTL is how long cached metadata is used before it is pulled again. Defaults to a day
+func GetCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(viper.GetString("CACHE_TTL"))
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// GetCacheEnabled is false when the cache is turned off with --no-cache, or there is no home folder for it
+func GetCacheEnabled() bool {
+	if value, ok := overrides["NO_CACHE"].(bool); ok && value {
+		return false
+	}
+	return GetCacheFolder() != ""
+}
+func GetMaxRetries() int {
+	if value, ok := overrides["MAX_RETRIES"].(int); ok {
+		return value
+	}
+	return viper.GetInt("MAX_RETRIES")
+}
 
 func GetAllEnvironments() map[string]interface{} {
 	return viper.GetStringMap("ALL_ENVIRONMENTS")
@@ -19,7 +19,9 @@ func newCreateCommand() *cobra.Command {
 		Aliases: []string{"c"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			for _, environmentName := range args {
-				utilities.CreateEnvironment(environmentName)
+				if err := utilities.CreateEnvironment(environmentName); err != nil {
+					return err
+				}
 			}
 
 			if len(args) == 0 {
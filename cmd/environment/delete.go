@@ -42,6 +42,9 @@ func newDeleteCommand() *cobra.Command {
 					configs.SetCurrentEnvironment("")
 					delete(environments, current_environment)
 					viper.Set("ALL_ENVIRONMENTS", environments)
+					if err := utilities.ClearCache(current_environment); err != nil {
+						return err
+					}
 
 				}
 			} else {
@@ -61,11 +64,13 @@ func newDeleteCommand() *cobra.Command {
 						configs.SetCurrentEnvironment("")
 						delete(environments, tenant)
 						viper.Set("ALL_ENVIRONMENTS", environments)
+						if err := utilities.ClearCache(tenant); err != nil {
+							return err
+						}
 
 						return nil
 					} else {
-						log.Fatal("Environment does not exist.")
-						return nil
+						return utilities.ValidationErrorf("environment %q does not exist", tenant)
 					}
 
 				}
@@ -41,7 +41,7 @@ func newShowCommand() *cobra.Command {
 					}
 					formatted, err := json.MarshalIndent(environments[current_environment], "", "  ")
 					if err != nil {
-						log.Fatal(err)
+						return err
 					}
 					fmt.Println(string(formatted))
 					return nil
@@ -62,14 +62,13 @@ func newShowCommand() *cobra.Command {
 						}
 						formatted, err := json.MarshalIndent(environments[tenant], "", "  ")
 						if err != nil {
-							log.Fatal(err)
+							return err
 						}
 						fmt.Println(string(formatted))
 
 						return nil
 					} else {
-						log.Fatal("Environment does not exist\nUse `nerm env create " + tenant + "` to create it.")
-						return nil
+						return utilities.ValidationErrorf("environment %q does not exist. Use `nerm env create %s` to create it", tenant, tenant)
 					}
 
 				}
@@ -38,7 +38,9 @@ func newUpdateCommand() *cobra.Command {
 							break
 						}
 					}
-					utilities.UpdateEnvironment(current_environment)
+					if err := utilities.UpdateEnvironment(current_environment); err != nil {
+						return err
+					}
 				}
 			} else {
 				for _, tenant := range args {
@@ -54,11 +56,12 @@ func newUpdateCommand() *cobra.Command {
 								break
 							}
 						}
-						utilities.UpdateEnvironment(tenant)
+						if err := utilities.UpdateEnvironment(tenant); err != nil {
+							return err
+						}
 
 					} else {
-						log.Fatal("Environment does not exist.")
-						return nil
+						return utilities.ValidationErrorf("environment %q does not exist", tenant)
 					}
 
 				}
@@ -5,10 +5,8 @@ package health_check
 
 import (
 	"fmt"
-	"io/ioutil"
-	"log"
 	"nerm/cmd/configs"
-	"net/http"
+	"nerm/cmd/utilities"
 
 	"github.com/spf13/cobra"
 )
@@ -21,38 +19,22 @@ func NewHealthCheckCommand() *cobra.Command {
 		Example: "nerm hc | nerm hc env_name",
 		Aliases: []string{"hc"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			baseurl := configs.GetBaseURL()
-
-			if len(args) == 0 {
-				tenant := configs.GetTenant()
+			environments := args
+			if len(environments) == 0 {
+				environments = []string{configs.GetCurrentEnvironment()}
+			}
 
-				response, err := http.Get("http://" + tenant + "." + baseurl + "/health_check")
+			for _, environmentName := range environments {
+				client, err := utilities.NewClientForEnvironment(environmentName)
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
-				data, err := ioutil.ReadAll(response.Body)
+
+				data, err := client.WithContext(cmd.Context()).HealthCheck()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				fmt.Println(string(data))
-			} else {
-				for _, environmentName := range args {
-					old_env := configs.GetCurrentEnvironment()
-					configs.SetCurrentEnvironment(environmentName)
-					tenant := configs.GetTenant()
-
-					response, err := http.Get("http://" + tenant + "." + baseurl + "/health_check")
-					if err != nil {
-						log.Fatal(err)
-					}
-					data, err := ioutil.ReadAll(response.Body)
-					if err != nil {
-						log.Fatal(err)
-					}
-					fmt.Println(string(data))
-					configs.SetCurrentEnvironment(old_env)
-				}
-
 			}
 
 			return nil
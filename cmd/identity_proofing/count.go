@@ -21,6 +21,11 @@ func newIdentityProofingCountCommand() *cobra.Command {
 		Example: "nerm idproofing count",
 		Aliases: []string{"c"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			var metadata ResponseMetaData
 
 			var finalValues [2]string
@@ -32,22 +37,30 @@ func newIdentityProofingCountCommand() *cobra.Command {
 
 			bar := progressbar.Default(2)
 
-			pass_resp, pass_err := utilities.MakeAPIRequests("get", "identity_proofing_results", "", params.Encode(), nil)
-			utilities.CheckError(pass_err)
+			pass_resp, pass_err := client.Get("identity_proofing_results", "", params.Encode())
+			if pass_err != nil {
+				return pass_err
+			}
 
 			err := json.Unmarshal(pass_resp, &metadata)
-			utilities.CheckError(err)
+			if err != nil {
+				return err
+			}
 
 			finalValues[0] = strconv.Itoa(metadata.Metadata.Total)
 
 			bar.Add(1)
 
 			params.Set("result", "fail")
-			fail_resp, fail_err := utilities.MakeAPIRequests("get", "identity_proofing_results", "", params.Encode(), nil)
-			utilities.CheckError(fail_err)
+			fail_resp, fail_err := client.Get("identity_proofing_results", "", params.Encode())
+			if fail_err != nil {
+				return fail_err
+			}
 
 			err = json.Unmarshal(fail_resp, &metadata)
-			utilities.CheckError(err)
+			if err != nil {
+				return err
+			}
 
 			finalValues[1] = strconv.Itoa(metadata.Metadata.Total)
 
@@ -4,7 +4,6 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package identity_proofing
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"nerm/cmd/configs"
@@ -21,23 +20,33 @@ func newIDProofingResultGetCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "get",
 		Short:   "Pulls IDP Results from current environment",
-		Long:    "Pulls Identity Proofing Results from current environment based on query parameters. Stores data in a CSV and JSON file at the defaul output location",
+		Long:    "Pulls Identity Proofing Results from current environment based on query parameters. Stores data at the defaul output location in the formats set with --format (JSON and CSV by default)",
 		Example: "nerm idproofing get --result fail",
 		Aliases: []string{"g"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			profile_id := cmd.Flags().Lookup("profile_id").Value.String()
 			workflow_session_id := cmd.Flags().Lookup("workflow_session_id").Value.String()
 			result := cmd.Flags().Lookup("result").Value.String()
+			resume := cmd.Flags().Lookup("resume").Value.String()
 			limitInt := 100
 
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
 			getLimitInt := math.MaxInt32
 
 			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_IDP_Export" + strconv.Itoa(int(time.Now().Unix()))
 
-			var resp []byte
-			var requestErr error
-
-			createIdentityProofingJsonFile(outputLoc + ".json")
+			var checkpoint *utilities.Checkpoint
+			var output *utilities.Exporter[IdentityProofingJsonFileData]
+			startOffset := 0
 
 			params := url.Values{}
 			params.Add("metadata", "true") // always include metadata for limit/offsets
@@ -53,54 +62,95 @@ func newIDProofingResultGetCommand() *cobra.Command {
 				params.Add("result", result)
 			}
 
-			params.Add("limit", "100")
-
-			// make first call to get the total number of results to be returned
-			resp, requestErr = utilities.MakeAPIRequests("get", "identity_proofing_results", "", params.Encode(), nil)
+			pager := utilities.NewPaginator[IdentityProofingJsonFileData](client, "identity_proofing_results", "identity_proofing_results", params)
 
-			utilities.CheckError(requestErr)
-
-			var idp_result IdentityProofingResponse
-			var respMetaData ResponseMetaData
-
-			err := json.Unmarshal(resp, &idp_result)
-			utilities.CheckError(err)
+			if resume != "" {
+				// carry on from where a previous run stopped, with the same environment, parameters and files
+				checkpoint, err = utilities.LoadCheckpoint(resume, "idproofing get")
+				if err != nil {
+					return err
+				}
 
-			err = json.Unmarshal(resp, &respMetaData)
-			utilities.CheckError(err)
+				resumeClient, clientErr := utilities.NewClientForEnvironment(checkpoint.Environment)
+				if clientErr != nil {
+					return clientErr
+				}
+				pager.Client = resumeClient
 
-			if getLimitInt > respMetaData.Metadata.Total {
-				getLimitInt = respMetaData.Metadata.Total
-			}
+				pager.Params, err = url.ParseQuery(checkpoint.Params)
+				if err != nil {
+					return utilities.ValidationErrorf("reading checkpoint %s: %w", resume, err)
+				}
+				getLimitInt = checkpoint.GetLimit
+				outputLoc = checkpoint.OutputLoc
+				startOffset = checkpoint.Offset
 
-			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of results found
+				output, err = utilities.ResumeExporter(checkpoint, idpTable)
+				if err != nil {
+					return err
+				}
+			} else {
+				// make first call to get the total number of results to be returned
+				total, requestErr := pager.Total(cmd.Context())
+				if requestErr != nil {
+					return requestErr
+				}
 
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
+				if getLimitInt > total {
+					getLimitInt = total
+				}
 
-				params.Add("offset", strconv.Itoa(offset))
+				output, err = utilities.NewExporter(outputLoc, configs.GetOutputFormats(), idpTable)
+				if err != nil {
+					return err
+				}
 
-				resp, requestErr = utilities.MakeAPIRequests("get", "identity_proofing_results", "", params.Encode(), nil)
+				checkpoint = utilities.NewCheckpoint("idproofing get", configs.GetCurrentEnvironment(), outputLoc)
+				checkpoint.Formats = output.Formats
+				checkpoint.Params = params.Encode()
+				checkpoint.GetLimit = getLimitInt
 
-				utilities.CheckError(requestErr)
+				if err := checkpoint.Advance(0, "", output); err != nil {
+					output.Close()
+					return err
+				}
+			}
+			defer output.Close() // leaves valid JSON files, even when the export stops early
 
-				err := json.Unmarshal(resp, &idp_result)
-				utilities.CheckError(err)
+			pager.Limit = limitInt
+			pager.GetLimit = getLimitInt
+			pager.Offset = startOffset
+			pager.Concurrency = concurrency
 
-				err = json.Unmarshal(resp, &respMetaData)
-				utilities.CheckError(err)
+			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of results found
+			bar.Set(startOffset)
 
-				if (offset + limitInt) >= getLimitInt {
+			err = pager.Pages(cmd.Context(), func(page utilities.Page[IdentityProofingJsonFileData]) error {
+				if page.Last {
 					bar.Set(getLimitInt)
 				} else {
-					bar.Add(limitInt) // increment progress
+					bar.Add(len(page.Records)) // increment progress
+				}
+
+				for _, rec := range page.Records {
+					if err := output.Write(rec); err != nil {
+						return err
+					}
 				}
 
-				printJsonToFile(outputLoc+".json", idp_result)
+				return checkpoint.Advance(page.NextOffset, "", output)
+			})
+			if err != nil {
+				return checkpoint.Failed(err)
 			}
 
-			endIdentityProofingJsonFile(outputLoc + ".json")
+			if err := output.Finish(); err != nil {
+				return err
+			}
 
-			convertJSONToCSV(outputLoc+".json", outputLoc+".csv")
+			if err := checkpoint.Remove(); err != nil {
+				return err
+			}
 
 			fmt.Println("\n" + "Identity Proofing data stored in " + outputLoc)
 
@@ -110,6 +160,8 @@ func newIDProofingResultGetCommand() *cobra.Command {
 	cmd.Flags().StringP("profile_id", "p", "", "ID of a specific Profile")
 	cmd.Flags().StringP("workflow_session_id", "w", "", "ID of a specific Workflow Session")
 	cmd.Flags().StringP("result", "r", "", "Find IDP results based on Pass/Fail")
+	cmd.Flags().String("concurrency", "1", "Number of pages to fetch at the same time")
+	cmd.Flags().String("resume", "", "Checkpoint file of a failed export to carry on from (stored next to the export as .checkpoint.json)")
 
 	return cmd
 }
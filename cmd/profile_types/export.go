@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profile_types
+
+import (
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newProfileTypesExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Exports Profile Types and their attributes",
+		Long:    "Exports Profile Types with the full definition of each of their attributes to a YAML file (or JSON with --json). IDs are left out and references between attributes use UIDs, so the file can be kept in version control and imported into another environment",
+		Example: "nerm profile-types export | nerm profile-types export -i 1234abcd-1234-abcd-5678-12345abcd5678 --json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			ids := cmd.Flags().Lookup("id").Value.String()
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			profileTypes, err := getProfileTypes(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			if ids != "" {
+				resolved, err := utilities.ResolveIDs(cmd.Context(), client, utilities.ProfileTypes, ids)
+				if err != nil {
+					return err
+				}
+
+				var selected []ProfileTypeData
+				for _, id := range resolved {
+					index := slices.IndexFunc(profileTypes, func(profileType ProfileTypeData) bool { return profileType.ID == id })
+					if index < 0 {
+						return utilities.ValidationErrorf("no profile type has the ID %q", id)
+					}
+					selected = append(selected, profileTypes[index])
+				}
+				profileTypes = selected
+			}
+
+			schema := SchemaFile{
+				Environment: configs.GetCurrentEnvironment(),
+				ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+			}
+
+			for _, profileType := range profileTypes {
+				attributes, err := utilities.ListAttributes(cmd.Context(), client, profileType.ID)
+				if err != nil {
+					return err
+				}
+
+				uidsByID := map[string]string{}
+				for _, attribute := range attributes {
+					uidsByID[attribute.ID] = attribute.UID
+				}
+
+				typeSchema := ProfileTypeSchema{UID: profileType.UID, Name: profileType.Name, Category: profileType.Category}
+				for _, attribute := range attributes {
+					typeSchema.NeAttributes = append(typeSchema.NeAttributes, attributeSchema(attribute, uidsByID))
+				}
+				// sorted so exports of the same profile type can be compared line by line
+				slices.SortFunc(typeSchema.NeAttributes, func(a, b AttributeSchema) int { return strings.Compare(a.UID, b.UID) })
+
+				schema.ProfileTypes = append(schema.ProfileTypes, typeSchema)
+				fmt.Println(profileType.Name+":", len(attributes), "attribute(s)")
+			}
+			slices.SortFunc(schema.ProfileTypes, func(a, b ProfileTypeSchema) int { return strings.Compare(a.Name, b.Name) })
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_ProfileTypes_Config" + strconv.Itoa(int(time.Now().Unix()))
+			if asJSON {
+				outputLoc += ".json"
+			} else {
+				outputLoc += ".yaml"
+			}
+
+			if err := writeSchemaFile(outputLoc, schema); err != nil {
+				return err
+			}
+
+			fmt.Println("\n" + "Profile type config stored in " + outputLoc)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("id", "i", "", "Profile type(s) to export (ID, UID or name). Use more than one with commas. Defaults to all of them")
+	cmd.Flags().Bool("json", false, "Write the file as JSON instead of YAML")
+
+	cmd.RegisterFlagCompletionFunc("id", utilities.CompleteNames(utilities.ProfileTypes))
+
+	return cmd
+}
@@ -0,0 +1,413 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profile_types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// importResult is one row of the report written by 'profile-types import'
+type importResult struct {
+	ProfileType string `json:"profile_type"`
+	Attribute   string `json:"attribute,omitempty"`
+	Action      string `json:"action"` // create, update, unchanged or skipped, then created, updated or failed once applied
+	Changes     string `json:"changes,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+var importResultTable = utilities.Table[importResult]{
+	Headers: []string{"ProfileType", "Attribute", "Action", "Changes", "Error"},
+	Row: func(r importResult) ([]string, map[string]string) {
+		return []string{r.ProfileType, r.Attribute, r.Action, r.Changes, r.Error}, nil
+	},
+}
+
+// typeImport is what needs to happen to one profile type and its attributes
+type typeImport struct {
+	schema     ProfileTypeSchema
+	id         string // ID in the target environment. Empty until the profile type is created
+	result     importResult
+	changed    map[string]any
+	attributes []attributeImport
+	uids       map[string]string // attribute IDs in the target environment, by UID
+}
+
+type attributeImport struct {
+	schema  AttributeSchema
+	id      string // ID in the target environment. Empty for new attributes
+	result  importResult
+	changed map[string]any
+}
+
+func newProfileTypesImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "import",
+		Short:   "Imports Profile Types and their attributes",
+		Long:    "Creates or updates the Profile Types and attributes of a file written by 'profile-types export' in the current environment. Profile types are matched by UID and then by name, and attributes by UID and then by label. Only the fields that are different are updated, and nothing is archived, unarchived or deleted. Use --dry_run to see the changes first",
+		Example: "nerm profile-types import -f sandbox_ProfileTypes_Config1700000000.yaml --dry_run",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			file := cmd.Flags().Lookup("file").Value.String()
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+
+			schema, err := readSchemaFile(file)
+			if err != nil {
+				return err
+			}
+			if len(schema.ProfileTypes) == 0 {
+				return utilities.ValidationErrorf("%s has no profile types to import", file)
+			}
+
+			// the plan is made from the attributes as they are now, not from the metadata cache
+			utilities.InvalidateCache(client, utilities.Attributes)
+
+			targetTypes, err := getProfileTypes(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			var imports []*typeImport
+			for _, typeSchema := range schema.ProfileTypes {
+				plan, err := planTypeImport(cmd.Context(), client, typeSchema, targetTypes)
+				if err != nil {
+					return err
+				}
+				imports = append(imports, plan)
+			}
+
+			counts := printImportPlan(imports)
+			fmt.Println("\n"+strconv.Itoa(counts["create"]), "to create,", counts["update"], "to update,", counts["unchanged"], "unchanged and", counts["skipped"], "skipped")
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_ProfileTypes_Import" + strconv.Itoa(int(time.Now().Unix()))
+
+			if dryRun || counts["create"]+counts["update"] == 0 {
+				if err := writeImportReport(outputLoc, imports); err != nil {
+					return err
+				}
+				if dryRun {
+					fmt.Println("Dry run - nothing was changed. Report stored in " + outputLoc)
+				} else {
+					fmt.Println("Nothing to import. Report stored in " + outputLoc)
+				}
+				return nil
+			}
+
+			// the profile type and attribute lists are pulled again next time, even if the import stops part way
+			defer utilities.InvalidateCache(client, utilities.ProfileTypes, utilities.Attributes)
+
+			failed := 0
+			for _, plan := range imports {
+				failed += applyTypeImport(client.WithContext(cmd.Context()), plan)
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+			}
+
+			if err := writeImportReport(outputLoc, imports); err != nil {
+				return err
+			}
+			fmt.Println("\nImport finished. Report stored in " + outputLoc)
+
+			if failed > 0 {
+				return fmt.Errorf("%d change(s) could not be made. See %s for the errors", failed, outputLoc)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("file", "f", "", "YAML or JSON file written by 'profile-types export'")
+	cmd.Flags().Bool("dry_run", false, "Show what would be created or updated without changing anything")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// planTypeImport matches a profile type of the file to the target environment and works out what needs to change
+func planTypeImport(ctx context.Context, client *utilities.Client, typeSchema ProfileTypeSchema, targetTypes []ProfileTypeData) (*typeImport, error) {
+	plan := &typeImport{schema: typeSchema, uids: map[string]string{}}
+	plan.result = importResult{ProfileType: typeSchema.Name}
+
+	index := slices.IndexFunc(targetTypes, func(profileType ProfileTypeData) bool {
+		return typeSchema.UID != "" && profileType.UID == typeSchema.UID
+	})
+	if index < 0 {
+		index = slices.IndexFunc(targetTypes, func(profileType ProfileTypeData) bool {
+			return strings.EqualFold(profileType.Name, typeSchema.Name)
+		})
+	}
+
+	var attributes []utilities.Attribute
+	if index < 0 {
+		plan.result.Action = "create"
+	} else {
+		target := targetTypes[index]
+		plan.id = target.ID
+
+		var changes []string
+		plan.changed, changes = fieldChanges(
+			map[string]any{"name": target.Name, "category": target.Category},
+			map[string]any{"name": typeSchema.Name, "category": typeSchema.Category},
+		)
+		plan.result.Action, plan.result.Changes = "unchanged", ""
+		if len(changes) > 0 {
+			slices.Sort(changes)
+			plan.result.Action, plan.result.Changes = "update", strings.Join(changes, "; ")
+		}
+
+		var err error
+		attributes, err = utilities.ListAttributes(ctx, client, target.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uidsByID := map[string]string{}
+	for _, attribute := range attributes {
+		uidsByID[attribute.ID] = attribute.UID
+		plan.uids[attribute.UID] = attribute.ID
+	}
+
+	for _, attribute := range typeSchema.NeAttributes {
+		plan.attributes = append(plan.attributes, planAttributeImport(typeSchema.Name, attribute, attributes, uidsByID))
+	}
+
+	// attributes that are filtered by another attribute go last, so the attribute they reference is created first
+	slices.SortStableFunc(plan.attributes, func(a, b attributeImport) int {
+		return boolOrder(a.schema.FilteringNeAttributeUID != "") - boolOrder(b.schema.FilteringNeAttributeUID != "")
+	})
+
+	return plan, nil
+}
+
+// planAttributeImport matches an attribute of the file to the attributes of its profile type in the target
+// environment, and works out what needs to change. uidsByID has the UIDs of the target attributes, by ID
+func planAttributeImport(profileType string, attribute AttributeSchema, attributes []utilities.Attribute, uidsByID map[string]string) attributeImport {
+	item := attributeImport{schema: attribute}
+	item.result = importResult{ProfileType: profileType, Attribute: attribute.Label}
+
+	match, ambiguous := findAttribute(attributes, attribute)
+	switch {
+	case ambiguous:
+		item.result.Action, item.result.Error = "skipped", "more than one attribute has the label "+attribute.Label+" (add its UID to the file)"
+	case match == nil && attribute.Archived:
+		item.result.Action, item.result.Error = "skipped", "archived, and not in this environment"
+	case match == nil:
+		item.result.Action = "create"
+	default:
+		item.id = match.ID
+
+		have := attributeFields(attributeSchema(*match, uidsByID))
+		have["filtering_ne_attribute_uid"] = uidsByID[match.FilteringNeAttributeID]
+		want := attributeFields(attribute)
+		want["filtering_ne_attribute_uid"] = attribute.FilteringNeAttributeUID
+		delete(want, "uid")      // attributes matched by label keep their UID
+		delete(want, "archived") // import never archives or unarchives an attribute that is already there
+
+		var changes []string
+		item.changed, changes = fieldChanges(have, want)
+		item.result.Action = "unchanged"
+		if len(changes) > 0 {
+			slices.Sort(changes)
+			item.result.Action, item.result.Changes = "update", strings.Join(changes, "; ")
+		}
+	}
+
+	return item
+}
+
+// findAttribute finds an attribute of the target environment by UID, and then by label
+func findAttribute(attributes []utilities.Attribute, attribute AttributeSchema) (*utilities.Attribute, bool) {
+	if attribute.UID != "" {
+		for i := range attributes {
+			if attributes[i].UID == attribute.UID {
+				return &attributes[i], false
+			}
+		}
+	}
+
+	var match *utilities.Attribute
+	for i := range attributes {
+		if attribute.Label != "" && strings.EqualFold(attributes[i].Label, attribute.Label) {
+			if match != nil {
+				return nil, true
+			}
+			match = &attributes[i]
+		}
+	}
+	return match, false
+}
+
+// applyTypeImport makes the changes of a plan and returns how many of them failed
+func applyTypeImport(client *utilities.Client, plan *typeImport) int {
+	failed := 0
+
+	switch plan.result.Action {
+	case "create":
+		body, _ := json.Marshal(map[string]any{"profile_type": map[string]any{"uid": plan.schema.UID, "name": plan.schema.Name, "category": plan.schema.Category}})
+		resp, err := client.Post("profile_types", "", body)
+		if err == nil {
+			var created struct {
+				ProfileType ProfileTypeData `json:"profile_type"`
+			}
+			err = json.Unmarshal(resp, &created)
+			plan.id = created.ProfileType.ID
+		}
+		if err == nil && plan.id == "" {
+			err = errors.New("the API did not return the new profile type")
+		}
+		if err != nil {
+			plan.result.Action, plan.result.Error = "failed", utilities.RowError(err)
+			failed++
+			// none of its attributes can be created without the profile type
+			for i := range plan.attributes {
+				if plan.attributes[i].result.Action == "create" {
+					plan.attributes[i].result.Action, plan.attributes[i].result.Error = "failed", "the profile type could not be created"
+					failed++
+				}
+			}
+			return failed
+		}
+		plan.result.Action = "created"
+	case "update":
+		body, _ := json.Marshal(map[string]any{"profile_type": plan.changed})
+		if _, err := client.Patch("profile_types", plan.id, body); err != nil {
+			plan.result.Action, plan.result.Error = "failed", utilities.RowError(err)
+			failed++
+		} else {
+			plan.result.Action = "updated"
+		}
+	}
+
+	for i := range plan.attributes {
+		item := &plan.attributes[i]
+
+		var fields map[string]any
+		switch item.result.Action {
+		case "create":
+			fields = attributeFields(item.schema)
+			fields["profile_type_id"] = plan.id
+		case "update":
+			fields = item.changed
+		default:
+			continue
+		}
+
+		// the filtering attribute is referenced by UID in the file, and by ID in the API
+		if _, ok := fields["filtering_ne_attribute_uid"]; ok || item.result.Action == "create" {
+			delete(fields, "filtering_ne_attribute_uid")
+			if uid := item.schema.FilteringNeAttributeUID; uid != "" {
+				id, found := plan.uids[uid]
+				if !found {
+					item.result.Action, item.result.Error = "failed", "the attribute it is filtered by ("+uid+") is not in this environment"
+					failed++
+					continue
+				}
+				fields["filtering_ne_attribute_id"] = id
+			} else if item.result.Action == "update" {
+				fields["filtering_ne_attribute_id"] = nil
+			}
+		}
+
+		body, _ := json.Marshal(map[string]any{"ne_attribute": fields})
+
+		if item.result.Action == "create" {
+			resp, err := client.Post("ne_attributes", "", body)
+			if err != nil {
+				item.result.Action, item.result.Error = "failed", utilities.RowError(err)
+				failed++
+				continue
+			}
+			var created struct {
+				NeAttribute utilities.Attribute `json:"ne_attribute"`
+			}
+			if err := json.Unmarshal(resp, &created); err == nil && created.NeAttribute.ID != "" {
+				plan.uids[created.NeAttribute.UID] = created.NeAttribute.ID
+			}
+			item.result.Action = "created"
+		} else {
+			if _, err := client.Patch("ne_attributes", item.id, body); err != nil {
+				item.result.Action, item.result.Error = "failed", utilities.RowError(err)
+				failed++
+				continue
+			}
+			item.result.Action = "updated"
+		}
+	}
+
+	return failed
+}
+
+// printImportPlan shows the profile types and attributes that are not unchanged, and counts each action
+func printImportPlan(imports []*typeImport) map[string]int {
+	counts := map[string]int{}
+	var finalValues [][]string
+
+	add := func(result importResult) {
+		counts[result.Action]++
+		if result.Action == "unchanged" {
+			return
+		}
+		changes := result.Changes
+		if result.Error != "" {
+			changes = result.Error
+		}
+		finalValues = append(finalValues, []string{result.ProfileType, result.Attribute, result.Action, changes})
+	}
+
+	for _, plan := range imports {
+		add(plan.result)
+		for _, item := range plan.attributes {
+			add(item.result)
+		}
+	}
+
+	if len(finalValues) > 0 {
+		printImportTable(finalValues)
+	}
+	return counts
+}
+
+func writeImportReport(outputLoc string, imports []*typeImport) error {
+	output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), importResultTable)
+	if err != nil {
+		return err
+	}
+	defer output.Abort()
+
+	for _, plan := range imports {
+		if err := output.Write(plan.result); err != nil {
+			return err
+		}
+		for _, item := range plan.attributes {
+			if err := output.Write(item.result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return output.Finish()
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profile_types
+
+import (
+	"maps"
+	"nerm/cmd/utilities"
+	"slices"
+	"testing"
+)
+
+var targetAttributes = []utilities.Attribute{
+	{ID: "a1", UID: "email", Label: "Email", DataType: "string", Type: "TextFieldAttribute"},
+	{ID: "a2", UID: "dept", Label: "Department", DataType: "string", Type: "SelectAttribute"},
+	{ID: "a3", UID: "team", Label: "Team", DataType: "string", Type: "SelectAttribute", FilteredByNeAttribute: true, FilteringNeAttributeID: "a2"},
+	{ID: "a4", UID: "old_code", Label: "Code", DataType: "string", Type: "TextFieldAttribute", Archived: true},
+	{ID: "a5", UID: "manager", Label: "Manager", DataType: "string", Type: "ProfileSearchAttribute"},
+	{ID: "a6", UID: "backup_manager", Label: "Manager", DataType: "string", Type: "ProfileSearchAttribute"},
+}
+
+func TestPlanAttributeImport(t *testing.T) {
+	uidsByID := map[string]string{}
+	for _, attribute := range targetAttributes {
+		uidsByID[attribute.ID] = attribute.UID
+	}
+
+	// schema is an attribute of the file, as exported from the target environment
+	schema := func(uid string) AttributeSchema {
+		for _, attribute := range targetAttributes {
+			if attribute.UID == uid {
+				return attributeSchema(attribute, uidsByID)
+			}
+		}
+		t.Fatalf("no attribute %s", uid)
+		return AttributeSchema{}
+	}
+
+	tests := []struct {
+		name      string
+		attribute AttributeSchema
+		action    string
+		id        string
+		changed   map[string]any
+	}{
+		{name: "unchanged", attribute: schema("email"), action: "unchanged", id: "a1", changed: map[string]any{}},
+		{name: "unchanged filtered attribute", attribute: schema("team"), action: "unchanged", id: "a3", changed: map[string]any{}},
+		{
+			name: "changed field",
+			attribute: func() AttributeSchema {
+				a := schema("email")
+				a.Description = "Work email"
+				return a
+			}(),
+			action: "update", id: "a1",
+			changed: map[string]any{"description": "Work email"},
+		},
+		{
+			name: "matched by label keeps its UID",
+			attribute: func() AttributeSchema {
+				a := schema("dept")
+				a.UID, a.ToolTip = "department", "Where they work"
+				return a
+			}(),
+			action: "update", id: "a2",
+			changed: map[string]any{"tool_tip": "Where they work"},
+		},
+		{
+			name: "archived in the file is not archived in the target",
+			attribute: func() AttributeSchema {
+				a := schema("email")
+				a.Archived = true
+				return a
+			}(),
+			action: "unchanged", id: "a1", changed: map[string]any{},
+		},
+		{
+			name: "archived in the target is not unarchived",
+			attribute: func() AttributeSchema {
+				a := schema("old_code")
+				a.Archived = false
+				return a
+			}(),
+			action: "unchanged", id: "a4", changed: map[string]any{},
+		},
+		{
+			name: "filtering attribute removed",
+			attribute: func() AttributeSchema {
+				a := schema("team")
+				a.FilteredByNeAttribute, a.FilteringNeAttributeUID = false, ""
+				return a
+			}(),
+			action: "update", id: "a3",
+			changed: map[string]any{"filtered_by_ne_attribute": false, "filtering_ne_attribute_uid": ""},
+		},
+		{name: "new attribute", attribute: AttributeSchema{UID: "phone", Label: "Phone"}, action: "create"},
+		{name: "new archived attribute", attribute: AttributeSchema{UID: "fax", Label: "Fax", Archived: true}, action: "skipped"},
+		{name: "ambiguous label", attribute: AttributeSchema{Label: "manager"}, action: "skipped"},
+		{name: "ambiguous label with a UID", attribute: AttributeSchema{UID: "backup_manager", Label: "Manager", DataType: "string", Type: "ProfileSearchAttribute"}, action: "unchanged", id: "a6", changed: map[string]any{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := planAttributeImport("Employee", test.attribute, targetAttributes, uidsByID)
+
+			if item.result.Action != test.action {
+				t.Fatalf("action is %q (%s%s), want %q", item.result.Action, item.result.Changes, item.result.Error, test.action)
+			}
+			if item.id != test.id {
+				t.Errorf("matched %q, want %q", item.id, test.id)
+			}
+			if test.changed != nil && !maps.Equal(item.changed, test.changed) {
+				t.Errorf("changed %v, want %v", item.changed, test.changed)
+			}
+		})
+	}
+}
+
+func TestFieldChanges(t *testing.T) {
+	have := map[string]any{"name": "Employee", "category": "employee", "crypt": false}
+
+	changed, changes := fieldChanges(have, map[string]any{"name": "Employee", "category": "employee", "crypt": false})
+	if len(changed) != 0 || len(changes) != 0 {
+		t.Errorf("same fields changed %v (%v)", changed, changes)
+	}
+
+	changed, changes = fieldChanges(have, map[string]any{"name": "Employees", "category": "employee", "crypt": true})
+	if want := map[string]any{"name": "Employees", "crypt": true}; !maps.Equal(changed, want) {
+		t.Errorf("changed %v, want %v", changed, want)
+	}
+	slices.Sort(changes)
+	if want := []string{"crypt: false -> true", "name: Employee -> Employees"}; !slices.Equal(changes, want) {
+		t.Errorf("changes are %q, want %q", changes, want)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profile_types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"nerm/cmd/utilities"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type ProfileTypeData struct {
+	ID       string `json:"id"`
+	UID      string `json:"uid"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// SchemaFile is the file written by 'profile-types export' and read by 'profile-types import'.
+// It has no IDs, as they are not the same in each tenant
+type SchemaFile struct {
+	Environment  string              `json:"environment" yaml:"environment"`
+	ExportedAt   string              `json:"exported_at" yaml:"exported_at"`
+	ProfileTypes []ProfileTypeSchema `json:"profile_types" yaml:"profile_types"`
+}
+
+type ProfileTypeSchema struct {
+	UID          string            `json:"uid" yaml:"uid"`
+	Name         string            `json:"name" yaml:"name"`
+	Category     string            `json:"category" yaml:"category"`
+	NeAttributes []AttributeSchema `json:"ne_attributes" yaml:"ne_attributes"`
+}
+
+// AttributeSchema is an ne_attribute without its IDs. The attribute it is filtered by is referenced by UID
+type AttributeSchema struct {
+	UID                     string `json:"uid" yaml:"uid"`
+	Label                   string `json:"label" yaml:"label"`
+	ToolTip                 string `json:"tool_tip,omitempty" yaml:"tool_tip,omitempty"`
+	DataType                string `json:"data_type" yaml:"data_type"`
+	DateFormat              string `json:"date_format,omitempty" yaml:"date_format,omitempty"`
+	Description             string `json:"description,omitempty" yaml:"description,omitempty"`
+	Archived                bool   `json:"archived,omitempty" yaml:"archived,omitempty"`
+	Type                    string `json:"type,omitempty" yaml:"type,omitempty"`
+	OwnershipDriven         bool   `json:"ownership_driven,omitempty" yaml:"ownership_driven,omitempty"`
+	AllowMultipleSelections bool   `json:"allow_multiple_selections,omitempty" yaml:"allow_multiple_selections,omitempty"`
+	SelectableStatus        string `json:"selectable_status,omitempty" yaml:"selectable_status,omitempty"`
+	FilteredByNeAttribute   bool   `json:"filtered_by_ne_attribute,omitempty" yaml:"filtered_by_ne_attribute,omitempty"`
+	FilteringNeAttributeUID string `json:"filtering_ne_attribute_uid,omitempty" yaml:"filtering_ne_attribute_uid,omitempty"`
+	RiskType                string `json:"risk_type,omitempty" yaml:"risk_type,omitempty"`
+	Crypt                   bool   `json:"crypt,omitempty" yaml:"crypt,omitempty"`
+	RiskScoreSetting        string `json:"risk_score_setting,omitempty" yaml:"risk_score_setting,omitempty"`
+}
+
+func NewProfileTypesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "profile-types",
+		Short:   "Export and import Profile Types",
+		Long:    "Export Profile Types and their attributes to a YAML or JSON file, and import them into another environment",
+		Example: "nerm profile-types export | nerm profile-types import -f sandbox_ProfileTypes_Config1700000000.yaml --dry_run",
+		Aliases: []string{"pt"},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newProfileTypesExportCommand(),
+		newProfileTypesImportCommand(),
+	)
+
+	return cmd
+}
+
+func printImportTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New("Profile Type", "Attribute", "Action", "Changes")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3])
+	}
+
+	tbl.Print()
+}
+
+func getProfileTypes(ctx context.Context, client *utilities.Client) ([]ProfileTypeData, error) {
+	return utilities.NewPaginator[ProfileTypeData](client, "profile_types", "profile_types", nil).All(ctx)
+}
+
+// isYAMLFile is true for files ending in .yaml or .yml. Everything else is read and written as JSON
+func isYAMLFile(fileLoc string) bool {
+	ext := strings.ToLower(filepath.Ext(fileLoc))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func writeSchemaFile(fileLoc string, schema SchemaFile) error {
+	var data []byte
+	var err error
+	if isYAMLFile(fileLoc) {
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(schema)
+		data = buf.Bytes()
+	} else {
+		data, err = json.MarshalIndent(schema, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileLoc, data, 0644)
+}
+
+func readSchemaFile(fileLoc string) (SchemaFile, error) {
+	var schema SchemaFile
+
+	data, err := os.ReadFile(fileLoc)
+	if err != nil {
+		return schema, utilities.ValidationErrorf("opening %s: %w", fileLoc, err)
+	}
+
+	if isYAMLFile(fileLoc) {
+		err = yaml.Unmarshal(data, &schema)
+	} else {
+		err = json.Unmarshal(data, &schema)
+	}
+	if err != nil {
+		return schema, utilities.ValidationErrorf("reading %s: %w", fileLoc, err)
+	}
+
+	for _, profileType := range schema.ProfileTypes {
+		if profileType.UID == "" && profileType.Name == "" {
+			return schema, utilities.ValidationErrorf("reading %s: every profile type needs a uid or a name", fileLoc)
+		}
+		for _, attribute := range profileType.NeAttributes {
+			if attribute.UID == "" && attribute.Label == "" {
+				return schema, utilities.ValidationErrorf("reading %s: every attribute of %s needs a uid or a label", fileLoc, profileType.Name)
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// attributeSchema removes the IDs from an attribute, so it can be compared with (or written to) another tenant
+func attributeSchema(attribute utilities.Attribute, uidsByID map[string]string) AttributeSchema {
+	return AttributeSchema{
+		UID:                     attribute.UID,
+		Label:                   attribute.Label,
+		ToolTip:                 attribute.ToolTip,
+		DataType:                attribute.DataType,
+		DateFormat:              attribute.DateFormat,
+		Description:             attribute.Description,
+		Archived:                attribute.Archived,
+		Type:                    attribute.Type,
+		OwnershipDriven:         attribute.OwnershipDriven,
+		AllowMultipleSelections: attribute.AllowMultipleSelections,
+		SelectableStatus:        attribute.SelectableStatus,
+		FilteredByNeAttribute:   attribute.FilteredByNeAttribute,
+		FilteringNeAttributeUID: uidsByID[attribute.FilteringNeAttributeID],
+		RiskType:                attribute.RiskType,
+		Crypt:                   attribute.Crypt,
+		RiskScoreSetting:        attribute.RiskScoreSetting,
+	}
+}
+
+// attributeFields are the fields of an attribute as they are sent to the API. The filtering attribute is
+// left out, as it needs the ID of the attribute in the tenant
+func attributeFields(attribute AttributeSchema) map[string]any {
+	return map[string]any{
+		"uid":                       attribute.UID,
+		"label":                     attribute.Label,
+		"tool_tip":                  attribute.ToolTip,
+		"data_type":                 attribute.DataType,
+		"date_format":               attribute.DateFormat,
+		"description":               attribute.Description,
+		"archived":                  attribute.Archived,
+		"type":                      attribute.Type,
+		"ownership_driven":          attribute.OwnershipDriven,
+		"allow_multiple_selections": attribute.AllowMultipleSelections,
+		"selectable_status":         attribute.SelectableStatus,
+		"filtered_by_ne_attribute":  attribute.FilteredByNeAttribute,
+		"risk_type":                 attribute.RiskType,
+		"crypt":                     attribute.Crypt,
+		"risk_score_setting":        attribute.RiskScoreSetting,
+	}
+}
+
+// fieldChanges lists the fields of want that are not the same in have, as "field: old -> new"
+func fieldChanges(have map[string]any, want map[string]any) (map[string]any, []string) {
+	changed := map[string]any{}
+	var changes []string
+
+	for field, value := range want {
+		if fmt.Sprint(have[field]) != fmt.Sprint(value) {
+			changed[field] = value
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, have[field], value))
+		}
+	}
+
+	return changed, changes
+}
@@ -0,0 +1,183 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+// profileChange is one row of the change file written by 'profiles compare'
+type profileChange struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ProfileTypeID string `json:"profile_type_id"`
+	Change        string `json:"change"`              // added, removed, status or attribute
+	Attribute     string `json:"attribute,omitempty"` // UID of the attribute that changed
+	Old           string `json:"old"`
+	New           string `json:"new"`
+}
+
+var profileChangeTable = utilities.Table[profileChange]{
+	Headers: []string{"ID", "Name", "ProfileTypeID", "Change", "Attribute", "Old", "New"},
+	Row: func(r profileChange) ([]string, map[string]string) {
+		return []string{r.ID, r.Name, r.ProfileTypeID, r.Change, r.Attribute, r.Old, r.New}, nil
+	},
+}
+
+// compareCounts are the totals for one profile type
+type compareCounts struct {
+	added, removed, status, attributes, unchanged int
+}
+
+func newProfileCompareCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "compare old.json new.json",
+		Short:   "Compares two Profile exports",
+		Long:    "Compares two JSON or JSON Lines exports of Profiles, matched by ID. Prints a count of the added, removed and changed profiles for each profile type, and writes a file with every change (profiles added or removed, status changes and attribute changes)",
+		Example: "nerm profiles compare dev_Profile_Export1700000000.json dev_Profile_Export1710000000.json",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldFile, newFile := args[0], args[1]
+
+			// the old export is kept in memory, the new one is read a profile at a time
+			oldProfiles := map[string]ProfileJsonFileData{}
+			err := utilities.EachJSONRecord(oldFile, func(profile ProfileJsonFileData) error {
+				oldProfiles[profile.ID] = profile
+				return nil
+			})
+			if err != nil {
+				return utilities.ValidationErrorf("reading %s: %w", oldFile, err)
+			}
+
+			outputLoc := configs.GetOutputFolder() + "Profile_Compare" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), profileChangeTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			counts := map[string]*compareCounts{} // by profile type ID
+			countsFor := func(profileTypeID string) *compareCounts {
+				if counts[profileTypeID] == nil {
+					counts[profileTypeID] = &compareCounts{}
+				}
+				return counts[profileTypeID]
+			}
+
+			seen := map[string]bool{}
+			err = utilities.EachJSONRecord(newFile, func(profile ProfileJsonFileData) error {
+				seen[profile.ID] = true
+				typeCounts := countsFor(profile.ProfileTypeID)
+
+				old, found := oldProfiles[profile.ID]
+				if !found {
+					typeCounts.added++
+					return output.Write(profileChange{ID: profile.ID, Name: profile.Name, ProfileTypeID: profile.ProfileTypeID, Change: "added", New: profile.Status})
+				}
+
+				changes := compareProfiles(old, profile)
+				if len(changes) == 0 {
+					typeCounts.unchanged++
+					return nil
+				}
+
+				statusChanged := false
+				for _, change := range changes {
+					if change.Change == "status" {
+						statusChanged = true
+					}
+					if err := output.Write(change); err != nil {
+						return err
+					}
+				}
+
+				if statusChanged {
+					typeCounts.status++
+				}
+				if len(changes) > 1 || !statusChanged {
+					typeCounts.attributes++
+				}
+				return nil
+			})
+			if err != nil {
+				return utilities.ValidationErrorf("reading %s: %w", newFile, err)
+			}
+
+			removed := maps.Keys(oldProfiles)
+			slices.Sort(removed)
+			for _, id := range removed {
+				if seen[id] {
+					continue
+				}
+				profile := oldProfiles[id]
+				countsFor(profile.ProfileTypeID).removed++
+				if err := output.Write(profileChange{ID: profile.ID, Name: profile.Name, ProfileTypeID: profile.ProfileTypeID, Change: "removed", Old: profile.Status}); err != nil {
+					return err
+				}
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			var finalValues [][]string
+			var total compareCounts
+			profileTypes := maps.Keys(counts)
+			slices.Sort(profileTypes)
+
+			for _, profileType := range profileTypes {
+				c := counts[profileType]
+				finalValues = append(finalValues, []string{profileType, strconv.Itoa(c.added), strconv.Itoa(c.removed), strconv.Itoa(c.status), strconv.Itoa(c.attributes), strconv.Itoa(c.unchanged)})
+
+				total.added += c.added
+				total.removed += c.removed
+				total.status += c.status
+				total.attributes += c.attributes
+				total.unchanged += c.unchanged
+			}
+			finalValues = append(finalValues, []string{"Total", strconv.Itoa(total.added), strconv.Itoa(total.removed), strconv.Itoa(total.status), strconv.Itoa(total.attributes), strconv.Itoa(total.unchanged)})
+
+			printCompareTable(finalValues)
+			fmt.Println("\n" + "Changes stored in " + outputLoc)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// compareProfiles lists what changed between two exports of the same profile
+func compareProfiles(before ProfileJsonFileData, after ProfileJsonFileData) []profileChange {
+	var changes []profileChange
+
+	change := func(kind string, attribute string, oldValue string, newValue string) profileChange {
+		return profileChange{ID: after.ID, Name: after.Name, ProfileTypeID: after.ProfileTypeID, Change: kind, Attribute: attribute, Old: oldValue, New: newValue}
+	}
+
+	if before.Status != after.Status {
+		changes = append(changes, change("status", "", before.Status, after.Status))
+	}
+
+	keys := append(maps.Keys(before.Attributes), maps.Keys(after.Attributes)...)
+	slices.Sort(keys)
+	keys = slices.Compact(keys) // remove duplicates
+
+	for _, key := range keys {
+		if before.Attributes[key] != after.Attributes[key] {
+			changes = append(changes, change("attribute", key, before.Attributes[key], after.Attributes[key]))
+		}
+	}
+
+	return changes
+}
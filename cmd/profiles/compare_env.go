@@ -0,0 +1,340 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+// envCompareResult is one row of the file written by 'profiles compare-env'
+type envCompareResult struct {
+	Key         string `json:"key"` // value of the --match-on field
+	Result      string `json:"result"`
+	SourceID    string `json:"source_id,omitempty"`
+	TargetID    string `json:"target_id,omitempty"`
+	Name        string `json:"name"`
+	ProfileType string `json:"profile_type"`
+	Field       string `json:"field,omitempty"` // name, status, profile_type or an attribute UID
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+}
+
+var envCompareResultTable = utilities.Table[envCompareResult]{
+	Headers: []string{"Key", "Result", "SourceID", "TargetID", "Name", "ProfileType", "Field", "Source", "Target"},
+	Row: func(r envCompareResult) ([]string, map[string]string) {
+		return []string{r.Key, r.Result, r.SourceID, r.TargetID, r.Name, r.ProfileType, r.Field, r.Source, r.Target}, nil
+	},
+}
+
+// envCompareCounts are the totals for one profile type
+type envCompareCounts struct {
+	matched, mismatched, missing, extra, duplicate int
+}
+
+func newProfileCompareEnvCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "compare-env",
+		Short:   "Compares the Profiles of two environments",
+		Long:    "Pulls the Profiles of two configured environments and matches them by UID, name or an attribute. Reports the profiles that are missing from the target, the extra profiles that are only in the target, and the fields (name, status, profile type and attributes) that do not match. Archived profiles are skipped. Profile types are compared by name, as their IDs are not the same in each tenant",
+		Example: "nerm profiles compare-env --source sandbox --target prod | nerm profiles compare-env --source sandbox --target prod --match-on employee_id --ignore manager",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source := cmd.Flags().Lookup("source").Value.String()
+			target := cmd.Flags().Lookup("target").Value.String()
+			matchOn := cmd.Flags().Lookup("match-on").Value.String()
+			ignore := cmd.Flags().Lookup("ignore").Value.String()
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			if source == target {
+				return utilities.ValidationErrorf("--source and --target are both %q. Use two different environments", source)
+			}
+
+			sourceClient, err := utilities.NewClientForEnvironment(source)
+			if err != nil {
+				return err
+			}
+			targetClient, err := utilities.NewClientForEnvironment(target)
+			if err != nil {
+				return err
+			}
+
+			ignored := map[string]bool{}
+			for _, attribute := range strings.Split(ignore, ",") {
+				if attribute = strings.TrimSpace(attribute); attribute != "" {
+					ignored[attribute] = true
+				}
+			}
+
+			sourceTypes, err := utilities.ObjectNames(cmd.Context(), sourceClient, utilities.ProfileTypes)
+			if err != nil {
+				return err
+			}
+			targetTypes, err := utilities.ObjectNames(cmd.Context(), targetClient, utilities.ProfileTypes)
+			if err != nil {
+				return err
+			}
+
+			outputLoc := configs.GetOutputFolder() + source + "_" + target + "_Profile_Compare" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), envCompareResultTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			matcher := newEnvMatcher(source, target, matchOn, ignored, sourceTypes, targetTypes)
+			writeResults := func(results []envCompareResult) error {
+				for _, result := range results {
+					if err := output.Write(result); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			// the source is kept in memory, the target is compared to it a page at a time
+			err = pullEnvironmentProfiles(cmd.Context(), sourceClient, source, concurrency, func(profile ProfileJsonFileData) error {
+				return writeResults(matcher.addSource(profile))
+			})
+			if err != nil {
+				return err
+			}
+
+			err = pullEnvironmentProfiles(cmd.Context(), targetClient, target, concurrency, func(profile ProfileJsonFileData) error {
+				return writeResults(matcher.compareTarget(profile))
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := writeResults(matcher.missing()); err != nil {
+				return err
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			var finalValues [][]string
+			var total envCompareCounts
+			profileTypes := maps.Keys(matcher.counts)
+			slices.Sort(profileTypes)
+
+			for _, profileType := range profileTypes {
+				c := matcher.counts[profileType]
+				finalValues = append(finalValues, []string{profileType, strconv.Itoa(c.matched), strconv.Itoa(c.mismatched), strconv.Itoa(c.missing), strconv.Itoa(c.extra), strconv.Itoa(c.duplicate)})
+
+				total.matched += c.matched
+				total.mismatched += c.mismatched
+				total.missing += c.missing
+				total.extra += c.extra
+				total.duplicate += c.duplicate
+			}
+			finalValues = append(finalValues, []string{"Total", strconv.Itoa(total.matched), strconv.Itoa(total.mismatched), strconv.Itoa(total.missing), strconv.Itoa(total.extra), strconv.Itoa(total.duplicate)})
+
+			fmt.Println()
+			printEnvCompareTable(finalValues)
+
+			if matcher.noKey > 0 {
+				fmt.Println("\n"+strconv.Itoa(matcher.noKey), "profile(s) have no", matchOn, "and were skipped")
+			}
+			fmt.Println("\n" + "Results stored in " + outputLoc)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("source", "", "Environment the profiles were promoted from")
+	cmd.Flags().String("target", "", "Environment the profiles were promoted to")
+	cmd.Flags().String("match-on", "uid", "Field used to match the profiles of both environments: uid, name, or the UID of an attribute")
+	cmd.Flags().String("ignore", "", "Attribute UIDs to leave out of the comparison, separated by commas (ie attributes that hold profile IDs)")
+	cmd.Flags().String("concurrency", "1", "Number of pages to pull from each environment at the same time")
+	cmd.MarkFlagRequired("source")
+	cmd.MarkFlagRequired("target")
+
+	return cmd
+}
+
+// envMatcher matches the profiles of the source environment to those of the target, and counts the results
+// for each profile type. The source profiles are kept in memory, the target profiles are compared one at a time
+type envMatcher struct {
+	source, target           string
+	matchOn                  string
+	ignored                  map[string]bool
+	sourceTypes, targetTypes map[string]string // profile type names, by ID
+
+	sourceProfiles map[string]ProfileJsonFileData // by match key
+	matched        map[string]bool
+	counts         map[string]*envCompareCounts // by profile type name
+	noKey          int                          // profiles without a value to match on
+}
+
+func newEnvMatcher(source string, target string, matchOn string, ignored map[string]bool, sourceTypes map[string]string, targetTypes map[string]string) *envMatcher {
+	return &envMatcher{
+		source: source, target: target, matchOn: matchOn, ignored: ignored, sourceTypes: sourceTypes, targetTypes: targetTypes,
+		sourceProfiles: map[string]ProfileJsonFileData{}, matched: map[string]bool{}, counts: map[string]*envCompareCounts{},
+	}
+}
+
+func (m *envMatcher) countsFor(profileType string) *envCompareCounts {
+	if m.counts[profileType] == nil {
+		m.counts[profileType] = &envCompareCounts{}
+	}
+	return m.counts[profileType]
+}
+
+// addSource keeps a profile of the source environment to be matched. Only the first profile with a key is kept,
+// the others are returned as duplicates
+func (m *envMatcher) addSource(profile ProfileJsonFileData) []envCompareResult {
+	key := profileMatchKey(profile, m.matchOn)
+	if key == "" {
+		m.noKey++
+		return nil
+	}
+
+	if _, found := m.sourceProfiles[key]; found {
+		profileType := m.sourceTypes[profile.ProfileTypeID]
+		m.countsFor(profileType).duplicate++
+		return []envCompareResult{{Key: key, Result: "duplicate", SourceID: profile.ID, Name: profile.Name, ProfileType: profileType, Field: m.matchOn, Source: "more than one profile in " + m.source + " has this value"}}
+	}
+	m.sourceProfiles[key] = profile
+	return nil
+}
+
+// compareTarget matches a profile of the target environment to the source, and returns a result for each field
+// that does not match. It returns nothing for a profile that matches
+func (m *envMatcher) compareTarget(profile ProfileJsonFileData) []envCompareResult {
+	key := profileMatchKey(profile, m.matchOn)
+	if key == "" {
+		m.noKey++
+		return nil
+	}
+
+	targetType := m.targetTypes[profile.ProfileTypeID]
+	result := envCompareResult{Key: key, TargetID: profile.ID, Name: profile.Name, ProfileType: targetType}
+
+	if m.matched[key] {
+		m.countsFor(targetType).duplicate++
+		result.Result, result.Field, result.Target = "duplicate", m.matchOn, "more than one profile in "+m.target+" has this value"
+		return []envCompareResult{result}
+	}
+
+	sourceProfile, found := m.sourceProfiles[key]
+	if !found {
+		m.countsFor(targetType).extra++
+		result.Result = "extra"
+		return []envCompareResult{result}
+	}
+	m.matched[key] = true
+
+	result.SourceID = sourceProfile.ID
+	fields := map[string][2]string{
+		"name":         {sourceProfile.Name, profile.Name},
+		"status":       {sourceProfile.Status, profile.Status},
+		"profile_type": {m.sourceTypes[sourceProfile.ProfileTypeID], targetType},
+	}
+	for uid, value := range sourceProfile.Attributes {
+		fields[uid] = [2]string{value, profile.Attributes[uid]}
+	}
+	for uid, value := range profile.Attributes {
+		fields[uid] = [2]string{sourceProfile.Attributes[uid], value}
+	}
+
+	names := maps.Keys(fields)
+	slices.Sort(names)
+
+	var results []envCompareResult
+	for _, field := range names {
+		values := fields[field]
+		if m.ignored[field] || values[0] == values[1] {
+			continue
+		}
+		result.Result, result.Field, result.Source, result.Target = "mismatched", field, values[0], values[1]
+		results = append(results, result)
+	}
+
+	if len(results) > 0 {
+		m.countsFor(targetType).mismatched++
+	} else {
+		m.countsFor(targetType).matched++
+	}
+	return results
+}
+
+// missing returns the source profiles that were not matched to a target profile, in key order
+func (m *envMatcher) missing() []envCompareResult {
+	keys := maps.Keys(m.sourceProfiles)
+	slices.Sort(keys)
+
+	var results []envCompareResult
+	for _, key := range keys {
+		if m.matched[key] {
+			continue
+		}
+		profile := m.sourceProfiles[key]
+		profileType := m.sourceTypes[profile.ProfileTypeID]
+		m.countsFor(profileType).missing++
+		results = append(results, envCompareResult{Key: key, Result: "missing", SourceID: profile.ID, Name: profile.Name, ProfileType: profileType})
+	}
+	return results
+}
+
+// profileMatchKey is the value a profile is matched on
+func profileMatchKey(profile ProfileJsonFileData, matchOn string) string {
+	switch matchOn {
+	case "uid":
+		return profile.UID
+	case "name":
+		return profile.Name
+	default:
+		return profile.Attributes[matchOn]
+	}
+}
+
+// pullEnvironmentProfiles calls fn with each profile of an environment that is not archived
+func pullEnvironmentProfiles(ctx context.Context, client *utilities.Client, environment string, concurrency int, fn func(profile ProfileJsonFileData) error) error {
+	params := url.Values{}
+	params.Add("metadata", "true")
+
+	pager := utilities.NewPaginator[ProfileJsonFileData](client, "profiles", "profiles", params)
+	pager.Limit = 500
+	pager.Concurrency = concurrency
+
+	total, err := pager.Total(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Pulling", total, "profile(s) from", environment)
+	bar := progressbar.Default(int64(total))
+	defer bar.Finish()
+
+	return pager.Pages(ctx, func(page utilities.Page[ProfileJsonFileData]) error {
+		for _, profile := range page.Records {
+			if profile.Archived {
+				continue
+			}
+			if err := fn(profile); err != nil {
+				return err
+			}
+		}
+		bar.Add(len(page.Records))
+		return nil
+	})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"slices"
+	"testing"
+)
+
+func envProfile(id string, uid string, attributes map[string]string) ProfileJsonFileData {
+	return ProfileJsonFileData{ID: id, UID: uid, Name: uid, ProfileTypeID: "t-" + id[:1], Status: "Active", Attributes: attributes}
+}
+
+func TestEnvMatcher(t *testing.T) {
+	// profile type IDs are different in each environment, the names are compared
+	sourceTypes := map[string]string{"t-s": "Employee"}
+	targetTypes := map[string]string{"t-t": "Employee"}
+
+	tests := []struct {
+		name    string
+		matchOn string
+		ignored map[string]bool
+		source  []ProfileJsonFileData
+		target  []ProfileJsonFileData
+		want    []string // result, key and field of each result
+		counts  envCompareCounts
+		noKey   int
+	}{
+		{
+			name:   "matched",
+			source: []ProfileJsonFileData{envProfile("s1", "jdoe", map[string]string{"email": "jane@example.com"})},
+			target: []ProfileJsonFileData{envProfile("t1", "jdoe", map[string]string{"email": "jane@example.com"})},
+			counts: envCompareCounts{matched: 1},
+		},
+		{
+			name:   "mismatched",
+			source: []ProfileJsonFileData{envProfile("s1", "jdoe", map[string]string{"email": "jane@example.com", "title": "Engineer"})},
+			target: []ProfileJsonFileData{envProfile("t1", "jdoe", map[string]string{"email": "jane@new.example.com", "manager": "t9"})},
+			want:   []string{"mismatched jdoe email", "mismatched jdoe manager", "mismatched jdoe title"},
+			counts: envCompareCounts{mismatched: 1},
+		},
+		{
+			name:    "ignored attributes",
+			ignored: map[string]bool{"manager": true},
+			source:  []ProfileJsonFileData{envProfile("s1", "jdoe", map[string]string{"manager": "s9"})},
+			target:  []ProfileJsonFileData{envProfile("t1", "jdoe", map[string]string{"manager": "t9"})},
+			counts:  envCompareCounts{matched: 1},
+		},
+		{
+			name:   "missing and extra",
+			source: []ProfileJsonFileData{envProfile("s1", "jdoe", nil)},
+			target: []ProfileJsonFileData{envProfile("t1", "jsmith", nil)},
+			want:   []string{"extra jsmith ", "missing jdoe "},
+			counts: envCompareCounts{missing: 1, extra: 1},
+		},
+		{
+			name:   "duplicate key in the source",
+			source: []ProfileJsonFileData{envProfile("s1", "jdoe", nil), envProfile("s2", "jdoe", nil)},
+			target: []ProfileJsonFileData{envProfile("t1", "jdoe", nil)},
+			want:   []string{"duplicate jdoe uid"},
+			counts: envCompareCounts{matched: 1, duplicate: 1},
+		},
+		{
+			name:   "duplicate key in the target",
+			source: []ProfileJsonFileData{envProfile("s1", "jdoe", nil)},
+			target: []ProfileJsonFileData{envProfile("t1", "jdoe", nil), envProfile("t2", "jdoe", nil)},
+			want:   []string{"duplicate jdoe uid"},
+			counts: envCompareCounts{matched: 1, duplicate: 1},
+		},
+		{
+			name:    "match on an attribute",
+			matchOn: "employee_id",
+			source:  []ProfileJsonFileData{envProfile("s1", "jdoe", map[string]string{"employee_id": "100"}), envProfile("s2", "nobody", nil)},
+			target:  []ProfileJsonFileData{envProfile("t1", "jane.doe", map[string]string{"employee_id": "100"})},
+			want:    []string{"mismatched 100 name"},
+			counts:  envCompareCounts{mismatched: 1},
+			noKey:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matchOn := test.matchOn
+			if matchOn == "" {
+				matchOn = "uid"
+			}
+			matcher := newEnvMatcher("sandbox", "prod", matchOn, test.ignored, sourceTypes, targetTypes)
+
+			var results []envCompareResult
+			for _, profile := range test.source {
+				results = append(results, matcher.addSource(profile)...)
+			}
+			for _, profile := range test.target {
+				results = append(results, matcher.compareTarget(profile)...)
+			}
+			results = append(results, matcher.missing()...)
+
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Result+" "+result.Key+" "+result.Field)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+
+			var counts envCompareCounts
+			if c := matcher.counts["Employee"]; c != nil {
+				counts = *c
+			}
+			if counts != test.counts {
+				t.Errorf("counts are %+v, want %+v", counts, test.counts)
+			}
+			if matcher.noKey != test.noKey {
+				t.Errorf("%d profile(s) had no key, want %d", matcher.noKey, test.noKey)
+			}
+		})
+	}
+}
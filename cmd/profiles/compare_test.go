@@ -0,0 +1,52 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareProfiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		after func(p *ProfileJsonFileData)
+		want  []profileChange
+	}{
+		{name: "unchanged"},
+		{
+			name:  "status",
+			after: func(p *ProfileJsonFileData) { p.Status = "Terminated" },
+			want:  []profileChange{{Change: "status", Old: "Active", New: "Terminated"}},
+		},
+		{
+			name:  "attributes changed, added and removed",
+			after: func(p *ProfileJsonFileData) { p.Attributes = map[string]string{"title": "Manager", "manager": "p9"} },
+			want: []profileChange{
+				{Change: "attribute", Attribute: "email", Old: "jane@example.com", New: ""},
+				{Change: "attribute", Attribute: "manager", Old: "", New: "p9"},
+				{Change: "attribute", Attribute: "title", Old: "Engineer", New: "Manager"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before, after := testProfile(), testProfile()
+			if test.after != nil {
+				test.after(&after)
+			}
+
+			var want []profileChange
+			for _, change := range test.want {
+				change.ID, change.Name, change.ProfileTypeID = after.ID, after.Name, after.ProfileTypeID
+				want = append(want, change)
+			}
+
+			if got := compareProfiles(before, after); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ package profiles
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"nerm/cmd/utilities"
 	"net/url"
 	"strconv"
@@ -24,30 +23,24 @@ func newProfileCountCommand() *cobra.Command {
 		Aliases: []string{"c"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			endTotal := 0
 			allStatuses := [4]string{"Active", "Inactive", "On Leave", "Terminated"}
 
 			var finalValues [][]string
 
-			type_params := url.Values{}
-			type_params.Add("limit", "100")
-			type_params.Add("metadata", "true")
-
-			types_resp, types_err := utilities.MakeAPIRequests("get", "profile_types", "", type_params.Encode(), nil)
-			if types_err != nil {
-				log.Fatal(types_err)
-			}
-			// dumped_res, dump_err := httputil.DumpResponse(types_resp, true)
-
-			var result ProfileTypeResponse
-			err := json.Unmarshal(types_resp, &result)
-			if err != nil { // Parse []byte to the go struct pointer
-				fmt.Println("Can not unmarshal JSON")
+			profileTypes, typesErr := utilities.ListObjects(cmd.Context(), client, utilities.ProfileTypes)
+			if typesErr != nil {
+				return typesErr
 			}
 
-			bar := progressbar.Default(int64(len(result.ProfileTypes))) // set progress to number of profile types found
+			bar := progressbar.Default(int64(len(profileTypes))) // set progress to number of profile types found
 
-			for _, rec := range result.ProfileTypes {
+			for _, rec := range profileTypes {
 				bar.Add(1) // increment progress
 				var typeValues []string
 				runningTotal := 0
@@ -65,9 +58,9 @@ func newProfileCountCommand() *cobra.Command {
 
 					params.Add("status", string(status))
 
-					resp, err := utilities.MakeAPIRequests("get", "profiles", "", params.Encode(), nil)
+					resp, err := client.Get("profiles", "", params.Encode())
 					if err != nil {
-						log.Fatal(err)
+						return err
 					}
 					// fmt.Println(string(resp))
 
@@ -75,11 +68,11 @@ func newProfileCountCommand() *cobra.Command {
 					var respMetaData ResponseMetaData
 					err = json.Unmarshal(resp, &profile_result)
 					if err != nil { // Parse []byte to the go struct pointer
-						fmt.Println("Can not unmarshal JSON", err)
+						return fmt.Errorf("can not unmarshal profiles: %w", err)
 					}
 					err = json.Unmarshal(resp, &respMetaData)
 					if err != nil { // Parse []byte to the go struct pointer
-						fmt.Println("Can not unmarshal JSON", err)
+						return fmt.Errorf("can not unmarshal profiles: %w", err)
 					}
 
 					typeValues = append(typeValues, strconv.Itoa(respMetaData.Metadata.Total))
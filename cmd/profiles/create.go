@@ -0,0 +1,330 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// profileFields are columns of a profile export that are not attributes. They are skipped when creating profiles,
+// so an export from another environment can be used as the input file
+var profileFields = []string{"id", "uid", "name", "profiletypeid", "profile_type_id", "idproofingstatus", "id_proofing_status", "archived", "updatedat", "updated_at", "createdat", "created_at"}
+
+type newProfile struct {
+	ProfileTypeID string            `json:"profile_type_id"`
+	Status        string            `json:"status"`
+	Attributes    map[string]string `json:"attributes"`
+}
+
+// createResult is one row of the results file written by 'profiles create'
+type createResult struct {
+	Row        int               `json:"row"`
+	Result     string            `json:"result"` // created, failed or invalid
+	ID         string            `json:"id,omitempty"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+var createResultTable = utilities.Table[createResult]{
+	Headers: []string{"Row", "Result", "ID", "Status", "Error"},
+	Row: func(r createResult) ([]string, map[string]string) {
+		return []string{strconv.Itoa(r.Row), r.Result, r.ID, r.Status, r.Error}, r.Attributes
+	},
+}
+
+func newProfileCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "create",
+		Short:   "Creates Profiles from a CSV or JSON file",
+		Long:    "Creates Profiles of one profile type from a CSV, TSV, JSON or JSON Lines file. Each column is matched to an attribute of the profile type by its UID or label, and every row is checked before anything is sent. A 'status' column sets the status of each profile. Writes a results file with the ID of each new profile, or why its row failed",
+		Example: "nerm profiles create -f new_profiles.csv --profile_type 1234abcd-1234-abcd-5678-12345abcd5678",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			file := cmd.Flags().Lookup("file").Value.String()
+			profile_type := cmd.Flags().Lookup("profile_type").Value.String()
+			defaultStatus := cmd.Flags().Lookup("status").Value.String()
+			batchSize := cmd.Flags().Lookup("batch_size").Value.String()
+
+			batchSizeInt, err := strconv.Atoi(batchSize)
+			if err != nil || batchSizeInt < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number for --batch_size", batchSize)
+			}
+			if batchSizeInt > 100 {
+				fmt.Println("Batch size can not be over 100 - Setting it back down to 100")
+				batchSizeInt = 100
+			}
+
+			defaultStatus, ok := matchProfileStatus(defaultStatus)
+			if !ok {
+				return utilities.ValidationErrorf("%q is not a valid --status. Use one of: %s", cmd.Flags().Lookup("status").Value.String(), strings.Join(profileStatuses, ", "))
+			}
+
+			profile_type, err = utilities.ResolveID(cmd.Context(), client, utilities.ProfileTypes, profile_type)
+			if err != nil {
+				return err
+			}
+
+			// make sure the profile type exists before reading its attributes
+			typeResp, err := client.Get("profile_types", profile_type, "")
+			if err != nil {
+				return err
+			}
+			var profileType struct {
+				ProfileType struct {
+					Name string `json:"name"`
+				} `json:"profile_type"`
+			}
+			if err := json.Unmarshal(typeResp, &profileType); err != nil {
+				return fmt.Errorf("can not unmarshal profile type: %w", err)
+			}
+
+			attributes, err := utilities.ListAttributes(cmd.Context(), client, profile_type)
+			if err != nil {
+				return err
+			}
+
+			columns, rows, err := utilities.ReadInputFile(file)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return utilities.ValidationErrorf("%s has no rows to create profiles from", file)
+			}
+
+			columnUIDs, statusColumn, err := mapColumnsToAttributes(columns, attributes, profileType.ProfileType.Name)
+			if err != nil {
+				return err
+			}
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Create" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), createResultTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			var batch []createResult
+			var batchProfiles []newProfile
+			invalid := 0
+
+			// check every row before anything is sent, so a bad file does not leave half its profiles created
+			for _, row := range rows {
+				result := createResult{Row: row.Number, Status: defaultStatus, Attributes: map[string]string{}}
+
+				for column, uid := range columnUIDs {
+					if value := row.Values[column]; value != "" {
+						result.Attributes[uid] = value
+					}
+				}
+
+				if statusColumn != "" && row.Values[statusColumn] != "" {
+					status, ok := matchProfileStatus(row.Values[statusColumn])
+					if !ok {
+						result.Error = fmt.Sprintf("%q is not a valid status", row.Values[statusColumn])
+					}
+					result.Status = status
+				}
+				if len(result.Attributes) == 0 {
+					result.Error = "the row has no attribute values"
+				}
+
+				if result.Error != "" {
+					result.Result = "invalid"
+					invalid++
+					if err := output.Write(result); err != nil {
+						return err
+					}
+					continue
+				}
+
+				batch = append(batch, result)
+				batchProfiles = append(batchProfiles, newProfile{ProfileTypeID: profile_type, Status: result.Status, Attributes: result.Attributes})
+			}
+
+			if invalid > 0 {
+				fmt.Println(invalid, "row(s) are not valid and will be skipped. They are listed in the results file")
+			}
+			fmt.Println("Creating", len(batch), profileType.ProfileType.Name, "profile(s)")
+
+			bar := progressbar.Default(int64(len(batch)))
+			created := 0
+			failed := 0
+
+			for start := 0; start < len(batch); start = start + batchSizeInt {
+				end := min(start+batchSizeInt, len(batch))
+				results := batch[start:end]
+
+				if err := createProfiles(client, batchProfiles[start:end], results); err != nil {
+					return err
+				}
+
+				for _, result := range results {
+					if result.Result == "created" {
+						created++
+					} else {
+						failed++
+					}
+					if err := output.Write(result); err != nil {
+						return err
+					}
+				}
+				if err := output.Flush(); err != nil { // keep the results of each batch on disk in case the command is stopped
+					return err
+				}
+
+				bar.Add(len(results))
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			fmt.Println("\nCreated", created, "profile(s).", failed, "failed and", invalid, "were not valid")
+			fmt.Println("Results stored in " + outputLoc)
+
+			if failed+invalid > 0 {
+				return fmt.Errorf("%d of %d row(s) were not created. See %s for the errors", failed+invalid, len(rows), outputLoc)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("file", "f", "", "CSV, TSV, JSON or JSON Lines file with a row for each new profile")
+	cmd.Flags().StringP("profile_type", "t", "", "Profile type of the new profiles (ID, UID or name)")
+	cmd.Flags().StringP("status", "s", "Active", "Status of the new profiles, for rows without a 'status' column (Active, Inactive, On Leave, Terminated)")
+	cmd.Flags().String("batch_size", "50", "Number of profiles to create with each request (100 at most)")
+
+	cmd.RegisterFlagCompletionFunc("profile_type", utilities.CompleteNames(utilities.ProfileTypes))
+
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagRequired("profile_type")
+
+	return cmd
+}
+
+// mapColumnsToAttributes matches each column of the input file to an attribute UID, by UID first and then by label.
+// It also returns the column holding the profile status, if there is one
+func mapColumnsToAttributes(columns []string, attributes []utilities.Attribute, profileTypeName string) (map[string]string, string, error) {
+	lookup := newAttributeLookup(attributes)
+	columnUIDs := map[string]string{}
+	statusColumn := ""
+	var skipped, unknown, archived, ambiguous []string
+
+	for _, column := range columns {
+		if column == "" {
+			continue
+		}
+
+		attribute, found, isAmbiguous := lookup.find(column)
+		if isAmbiguous {
+			ambiguous = append(ambiguous, column)
+			continue
+		}
+
+		switch {
+		case !found && strings.EqualFold(column, "status"):
+			statusColumn = column
+		case !found && slices.Contains(profileFields, strings.ToLower(column)):
+			skipped = append(skipped, column)
+		case !found:
+			unknown = append(unknown, column)
+		case attribute.Archived:
+			archived = append(archived, column)
+		default:
+			columnUIDs[column] = attribute.UID
+		}
+	}
+
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, "not attributes of "+profileTypeName+": "+strings.Join(unknown, ", "))
+	}
+	if len(archived) > 0 {
+		problems = append(problems, "archived attributes: "+strings.Join(archived, ", "))
+	}
+	if len(ambiguous) > 0 {
+		problems = append(problems, "labels used by more than one attribute (use the UID instead): "+strings.Join(ambiguous, ", "))
+	}
+	if len(problems) > 0 {
+		return nil, "", utilities.ValidationErrorf("the input file has columns that can not be used.\n  %s", strings.Join(problems, "\n  "))
+	}
+	if len(columnUIDs) == 0 {
+		return nil, "", utilities.ValidationErrorf("none of the columns of the input file are attributes of %s", profileTypeName)
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("Skipping columns that are not attributes:", strings.Join(skipped, ", "))
+	}
+
+	return columnUIDs, statusColumn, nil
+}
+
+// createProfiles sends one batch of new profiles and fills in the result of each one. If the API rejects the batch,
+// each profile is sent on its own so only the rows with a problem fail
+func createProfiles(client *utilities.Client, profiles []newProfile, results []createResult) error {
+	body, err := json.Marshal(map[string][]newProfile{"profiles": profiles})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post("profiles", "", body)
+	if err != nil {
+		var apiErr *utilities.APIError
+		rejected := errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity)
+
+		if rejected && len(profiles) > 1 {
+			for i := range profiles {
+				if err := createProfiles(client, profiles[i:i+1], results[i:i+1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for i := range results {
+			results[i].Result = "failed"
+			results[i].Error = utilities.RowError(err)
+		}
+		if client.Context().Err() != nil {
+			return client.Context().Err()
+		}
+		return nil
+	}
+
+	var created ProfileResponse
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return fmt.Errorf("can not unmarshal profiles: %w", err)
+	}
+
+	for i := range results {
+		if i < len(created.Profiles) && created.Profiles[i].ID != "" {
+			results[i].Result = "created"
+			results[i].ID = created.Profiles[i].ID
+		} else {
+			results[i].Result = "failed"
+			results[i].Error = "the API did not return a profile for this row"
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestMapColumnsToAttributes(t *testing.T) {
+	tests := []struct {
+		name         string
+		columns      []string
+		want         map[string]string
+		statusColumn string
+		wantErr      bool
+	}{
+		{
+			name:    "labels and UIDs",
+			columns: []string{"Email", "title", "backup_manager"},
+			want:    map[string]string{"Email": "email", "title": "title", "backup_manager": "backup_manager"},
+		},
+		{
+			name:         "status and profile fields",
+			columns:      []string{"uid", "name", "Status", "Email", ""},
+			want:         map[string]string{"Email": "email"},
+			statusColumn: "Status",
+		},
+		{name: "archived attribute", columns: []string{"Email", "Old Code"}, wantErr: true},
+		{name: "ambiguous label", columns: []string{"Email", "Manager"}, wantErr: true},
+		{name: "unknown column", columns: []string{"Email", "Favourite Colour"}, wantErr: true},
+		{name: "no attributes", columns: []string{"uid", "name"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columnUIDs, statusColumn, err := mapColumnsToAttributes(test.columns, testAttributes, "Employee")
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("no error, mapped %v", columnUIDs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(columnUIDs, test.want) {
+				t.Errorf("got %v, want %v", columnUIDs, test.want)
+			}
+			if statusColumn != test.statusColumn {
+				t.Errorf("status column is %q, want %q", statusColumn, test.statusColumn)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// deleteSampleSize is how many of the matched profiles are shown before asking to continue
+const deleteSampleSize = 10
+
+// deleteResult is one row of the report written by 'profiles delete'
+type deleteResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Result string `json:"result"` // archived, deleted, skipped, not_found or failed
+	Error  string `json:"error,omitempty"`
+}
+
+var deleteResultTable = utilities.Table[deleteResult]{
+	Headers: []string{"ID", "Name", "Result", "Error"},
+	Row: func(r deleteResult) ([]string, map[string]string) {
+		return []string{r.ID, r.Name, r.Result, r.Error}, nil
+	},
+}
+
+func newProfileDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "delete [profile IDs]",
+		Short:   "Archives or deletes Profiles",
+		Long:    "Archives Profiles (or deletes them with --hard) by ID, from the ID column of a CSV or JSON file, or from the results of an Advanced Search. Shows how many profiles match and a sample of them, then asks for a typed confirmation unless --yes is set. Every profile is saved to an undo file before anything is changed",
+		Example: "nerm profiles delete 1234abcd-1234-abcd-5678-12345abcd5678 | nerm profiles delete -f leavers.csv --hard | nerm profiles delete --advsearch 1234abcd-1234-abcd-5678-12345abcd5678 --yes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			ids := cmd.Flags().Lookup("id").Value.String()
+			file := cmd.Flags().Lookup("file").Value.String()
+			advsearch := cmd.Flags().Lookup("advsearch").Value.String()
+			hard, _ := cmd.Flags().GetBool("hard")
+			yes, _ := cmd.Flags().GetBool("yes")
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			advsearch, err = utilities.ResolveID(cmd.Context(), client, utilities.AdvancedSearches, advsearch)
+			if err != nil {
+				return err
+			}
+
+			action, done := "archive", "archived"
+			if hard {
+				action, done = "delete", "deleted"
+			}
+
+			if advsearch != "" && len(args) > 0 {
+				return utilities.ValidationErrorf("use either profile IDs or --advsearch, not both")
+			}
+
+			var profiles []ProfileJsonFileData
+			var skipped []deleteResult // profiles that are not found or do not need removing
+
+			if advsearch != "" {
+				// the search returns the full profiles, so nothing else needs to be pulled
+				pager := utilities.NewPaginator[ProfileJsonFileData](client, "advanced_search", "advanced_search", nil)
+				pager.Fetch = func(ctx context.Context, params string) ([]byte, error) {
+					return client.WithContext(ctx).RunAdvancedSearch(advsearch, params)
+				}
+				profiles, err = pager.All(cmd.Context())
+				if err != nil {
+					return err
+				}
+			} else {
+				var profileIDs []string
+				for _, id := range append(args, strings.Split(ids, ",")...) {
+					if id = strings.TrimSpace(id); id != "" {
+						profileIDs = append(profileIDs, id)
+					}
+				}
+
+				if file != "" {
+					fileIDs, err := readProfileIDs(file)
+					if err != nil {
+						return err
+					}
+					profileIDs = append(profileIDs, fileIDs...)
+				}
+
+				slices.Sort(profileIDs)
+				profileIDs = slices.Compact(profileIDs) // remove duplicates
+				if len(profileIDs) == 0 {
+					return utilities.ValidationErrorf("no profile IDs were given")
+				}
+
+				fmt.Println("Pulling", len(profileIDs), "profile(s)")
+				profiles, skipped, err = pullProfiles(cmd.Context(), client, profileIDs, concurrency)
+				if err != nil {
+					return err
+				}
+			}
+
+			// profiles that are already archived do not need archiving again
+			var targets []ProfileJsonFileData
+			for _, profile := range profiles {
+				if !hard && profile.Archived {
+					skipped = append(skipped, deleteResult{ID: profile.ID, Name: profile.Name, Result: "skipped", Error: "already archived"})
+					continue
+				}
+				targets = append(targets, profile)
+			}
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Delete" + strconv.Itoa(int(time.Now().Unix()))
+
+			// the report starts with the profiles that are skipped, and is only written once there is something to report
+			newReport := func() (*utilities.Exporter[deleteResult], error) {
+				output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), deleteResultTable)
+				if err != nil {
+					return nil, err
+				}
+				for _, result := range skipped {
+					if err := output.Write(result); err != nil {
+						output.Abort()
+						return nil, err
+					}
+				}
+				return output, nil
+			}
+
+			if len(targets) == 0 {
+				output, err := newReport()
+				if err != nil {
+					return err
+				}
+				if err := output.Finish(); err != nil {
+					return err
+				}
+				fmt.Println("No profiles to " + action + ". Report stored in " + outputLoc)
+				return nil
+			}
+
+			fmt.Println()
+			printProfileSampleTable(targets[:min(len(targets), deleteSampleSize)])
+			if len(targets) > deleteSampleSize {
+				fmt.Println("...and", len(targets)-deleteSampleSize, "more")
+			}
+			fmt.Println()
+
+			if !yes {
+				confirmation := action + " " + strconv.Itoa(len(targets))
+				fmt.Fprint(os.Stderr, "You are about to "+action+" "+strconv.Itoa(len(targets))+" profile(s) in "+configs.GetCurrentEnvironment()+". Type '"+confirmation+"' to continue:")
+
+				r := bufio.NewReader(cmd.InOrStdin())
+				s, _ := r.ReadString('\n')
+				if strings.TrimSpace(s) != confirmation {
+					return utilities.ValidationErrorf("confirmation did not match. No profiles were %s", done)
+				}
+			}
+
+			// save every profile as it is now. Archived profiles can be put back with 'profiles restore', and deleted
+			// ones created again (with new IDs) with 'profiles create'
+			undoLoc := outputLoc + "_undo.json"
+			undo, err := utilities.CreateJSONFile(undoLoc, false)
+			if err != nil {
+				return err
+			}
+			for _, profile := range targets {
+				if err := undo.Write(profile); err != nil {
+					undo.Close()
+					return err
+				}
+			}
+			if err := undo.Close(); err != nil {
+				return err
+			}
+			if hard {
+				fmt.Println("Profiles saved to " + undoLoc + ". Deleted profiles can not be restored, but 'nerm profiles create -f " + undoLoc + " --profile_type <type>' creates them again with new IDs")
+			} else {
+				fmt.Println("Profiles saved to " + undoLoc + ". Unarchive them with 'nerm profiles restore -f " + undoLoc + " --apply'")
+			}
+
+			output, err := newReport()
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			results := make([]deleteResult, len(targets))
+			bar := progressbar.Default(int64(len(targets)))
+
+			deleteProfile := func(ctx context.Context, i int) ([]byte, error) {
+				profile := targets[i]
+				results[i] = deleteResult{ID: profile.ID, Name: profile.Name, Result: done}
+
+				var err error
+				if hard {
+					_, err = client.WithContext(ctx).Delete("profiles", profile.ID, "")
+				} else {
+					_, err = client.WithContext(ctx).Patch("profiles", profile.ID, []byte(`{"profile":{"archived":true}}`))
+				}
+
+				if err != nil && ctx.Err() == nil {
+					results[i].Result, results[i].Error = "failed", utilities.RowError(err)
+					return nil, nil
+				}
+				return nil, err
+			}
+
+			failed := 0
+			handleResult := func(i int, resp []byte) (bool, error) {
+				bar.Add(1)
+				if results[i].Result == "failed" {
+					failed++
+				}
+				return true, output.Write(results[i])
+			}
+
+			if err := utilities.FetchPages(cmd.Context(), concurrency, 0, len(targets), 1, deleteProfile, handleResult); err != nil {
+				return err
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			fmt.Println("\n"+strings.ToUpper(done[:1])+done[1:], len(targets)-failed, "profile(s). Report stored in "+outputLoc)
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d profile(s) could not be %s. See %s for the errors", failed, len(targets), done, outputLoc)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("id", "i", "", "ID of the profile(s) to remove. Use more than one with commas")
+	cmd.Flags().StringP("file", "f", "", "CSV, TSV, JSON or JSON Lines file with an ID column of the profiles to remove")
+	cmd.Flags().StringP("advsearch", "a", "", "Advanced Search (ID or label). Every profile it finds is removed")
+	cmd.Flags().Bool("hard", false, "Delete the profiles instead of archiving them")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().String("concurrency", "1", "Number of profiles to pull or remove at the same time")
+
+	cmd.RegisterFlagCompletionFunc("advsearch", utilities.CompleteNames(utilities.AdvancedSearches))
+
+	cmd.MarkFlagsMutuallyExclusive("advsearch", "file")
+	cmd.MarkFlagsMutuallyExclusive("advsearch", "id")
+
+	return cmd
+}
+
+// readProfileIDs reads the ID column of an input file
+func readProfileIDs(fileLoc string) ([]string, error) {
+	columns, rows, err := utilities.ReadInputFile(fileLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	idColumn := ""
+	for _, column := range columns {
+		if strings.EqualFold(column, "id") {
+			idColumn = column
+		}
+	}
+	if idColumn == "" {
+		return nil, utilities.ValidationErrorf("%s needs an ID column", fileLoc)
+	}
+
+	var ids []string
+	for _, row := range rows {
+		if id := strings.TrimSpace(row.Values[idColumn]); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// pullProfiles gets the full profile of each ID. Profiles that do not exist are returned as not_found results
+func pullProfiles(ctx context.Context, client *utilities.Client, ids []string, concurrency int) ([]ProfileJsonFileData, []deleteResult, error) {
+	var profiles []ProfileJsonFileData
+	var notFound []deleteResult
+
+	bar := progressbar.Default(int64(len(ids)))
+
+	fetch := func(ctx context.Context, i int) ([]byte, error) {
+		resp, err := client.WithContext(ctx).Get("profiles", ids[i], "")
+		if utilities.IsNotFound(err) {
+			return nil, nil
+		}
+		return resp, err
+	}
+
+	handle := func(i int, resp []byte) (bool, error) {
+		bar.Add(1)
+
+		profile, found, err := findProfile(resp, ids[i], "")
+		if err != nil {
+			return false, err
+		}
+		if found {
+			profiles = append(profiles, profile)
+			return true, nil
+		}
+
+		notFound = append(notFound, deleteResult{ID: ids[i], Result: "not_found", Error: "no profile has this ID"})
+		return true, nil
+	}
+
+	err := utilities.FetchPages(ctx, concurrency, 0, len(ids), 1, fetch, handle)
+	return profiles, notFound, err
+}
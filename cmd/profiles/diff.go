@@ -4,13 +4,16 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package profiles
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"nerm/cmd/configs"
 	"nerm/cmd/utilities"
 	"net/url"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
@@ -21,17 +24,35 @@ func newProfileDiffCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "diff",
 		Short:   "Pulls a count of total Profiles via the Suite and Profile Service",
-		Long:    "Pulls a count of total Profiles via the Suite and Profile Service. This is to check for a different in the number of profiles",
-		Example: "nerm profiles diff",
+		Long:    "Pulls a count of total Profiles via the Suite and Profile Service. This is to check for a different in the number of profiles. With --deep, every profile ID is pulled from both backends and the profiles missing from either one are written to a file",
+		Example: "nerm profiles diff | nerm profiles diff --deep --attributes",
 		Aliases: []string{"d"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			allEnvs, allErr := cmd.Flags().GetBool("all_envs")
-			utilities.CheckError(allErr)
+			if allErr != nil {
+				return allErr
+			}
+
+			deep, _ := cmd.Flags().GetBool("deep")
+			if deep {
+				client, clientErr := utilities.ClientFromContext(cmd.Context())
+				if clientErr != nil {
+					return clientErr
+				}
+
+				hashAttributes, _ := cmd.Flags().GetBool("attributes")
+				concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+				if err != nil {
+					return err
+				}
+
+				return deepDiff(cmd.Context(), client, hashAttributes, concurrency)
+			}
 
 			backend := [2]string{"suite", "profile_service"}
 			var finalValues [][]string
 
-			currentEnv := configs.GetCurrentEnvironment() // store current env
+			currentEnv := configs.GetCurrentEnvironment()
 
 			if allEnvs {
 				allEnvironmentNames := configs.GetAllEnvironmentStrings()
@@ -40,10 +61,15 @@ func newProfileDiffCommand() *cobra.Command {
 				sort.Strings(keys)
 
 				for _, e := range keys {
-					configs.SetCurrentEnvironment(e)
 					// fmt.Println("|| maps values:", maps.Values(allEnvironmentNames))
 					fmt.Println("|-+-| Tenant: ", e)
 
+					envClient, clientErr := utilities.NewClientForEnvironment(e)
+					if clientErr != nil {
+						return clientErr
+					}
+					client := envClient.WithContext(cmd.Context())
+
 					bar := progressbar.Default(2) // set progress to number of profile types found
 
 					for _, rec := range backend {
@@ -59,21 +85,27 @@ func newProfileDiffCommand() *cobra.Command {
 						params.Add("metadata", "true")
 						params.Add("force_backend", rec)
 
-						resp, err := utilities.MakeAPIRequests("get", "profiles", "", params.Encode(), nil)
-						utilities.CheckError(err)
+						resp, err := client.Get("profiles", "", params.Encode())
+						if err != nil {
+							return err
+						}
 
 						var respMetaData ResponseMetaData
 						err = json.Unmarshal(resp, &respMetaData)
-						utilities.CheckError(err)
+						if err != nil {
+							return err
+						}
 
 						totalValues = append(totalValues, strconv.Itoa(respMetaData.Metadata.Total))
 						finalValues = append(finalValues, totalValues)
 					}
 				}
 
-				configs.SetCurrentEnvironment(currentEnv) // reset tenant
-
 			} else {
+				client, clientErr := utilities.ClientFromContext(cmd.Context())
+				if clientErr != nil {
+					return clientErr
+				}
 
 				bar := progressbar.Default(2) // set progress to number of profile types found
 
@@ -88,12 +120,16 @@ func newProfileDiffCommand() *cobra.Command {
 					params.Add("metadata", "true")
 					params.Add("force_backend", rec)
 
-					resp, err := utilities.MakeAPIRequests("get", "profiles", "", params.Encode(), nil)
-					utilities.CheckError(err)
+					resp, err := client.Get("profiles", "", params.Encode())
+					if err != nil {
+						return err
+					}
 
 					var respMetaData ResponseMetaData
 					err = json.Unmarshal(resp, &respMetaData)
-					utilities.CheckError(err)
+					if err != nil {
+						return err
+					}
 
 					totalValues = append(totalValues, strconv.Itoa(respMetaData.Metadata.Total))
 					finalValues = append(finalValues, totalValues)
@@ -107,5 +143,156 @@ func newProfileDiffCommand() *cobra.Command {
 	}
 
 	cmd.Flags().BoolP("all_envs", "a", false, "If true, Runs the Diff on all configured environments. Else, just the current one")
+	cmd.Flags().Bool("deep", false, "Pull every profile from both backends and write the profiles that are only in one of them to a file")
+	cmd.Flags().Bool("attributes", false, "With --deep, also report profiles whose attributes are not the same in both backends")
+	cmd.Flags().String("concurrency", "1", "With --deep, number of pages to pull from each backend at the same time")
+	cmd.MarkFlagsMutuallyExclusive("deep", "all_envs")
 	return cmd
 }
+
+// diffResult is one row of the file written by 'profiles diff --deep'
+type diffResult struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ProfileTypeID string `json:"profile_type_id"`
+	Result        string `json:"result"` // missing_from_suite, missing_from_profile_service or attributes_differ
+}
+
+var diffResultTable = utilities.Table[diffResult]{
+	Headers: []string{"ID", "Name", "ProfileTypeID", "Result"},
+	Row: func(r diffResult) ([]string, map[string]string) {
+		return []string{r.ID, r.Name, r.ProfileTypeID, r.Result}, nil
+	},
+}
+
+// backendProfile is what is kept of each suite profile while the profile service is pulled
+type backendProfile struct {
+	name          string
+	profileTypeID string
+	hash          uint64
+}
+
+// deepDiff pulls every profile ID from the suite, then from the profile service, and writes the profiles that are
+// only in one of them. Only a hash of the attributes is kept, so large tenants fit in memory
+func deepDiff(ctx context.Context, client *utilities.Client, hashAttributes bool, concurrency int) error {
+	pull := func(backend string, fn func(profile ProfileJsonFileData) error) (int, error) {
+		params := url.Values{}
+		params.Add("metadata", "true")
+		params.Add("force_backend", backend)
+		if !hashAttributes {
+			params.Add("exclude_attributes", "true")
+		}
+
+		pager := utilities.NewPaginator[ProfileJsonFileData](client, "profiles", "profiles", params)
+		pager.Limit = 500
+		pager.Concurrency = concurrency
+
+		total, err := pager.Total(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		fmt.Println("Pulling", total, "profile(s) from", backend)
+		bar := progressbar.Default(int64(total))
+
+		count := 0
+		err = pager.Pages(ctx, func(page utilities.Page[ProfileJsonFileData]) error {
+			for _, profile := range page.Records {
+				count++
+				if err := fn(profile); err != nil {
+					return err
+				}
+			}
+			bar.Add(len(page.Records))
+			return nil
+		})
+		bar.Finish()
+		return count, err
+	}
+
+	suite := map[string]backendProfile{}
+	suiteTotal, err := pull("suite", func(profile ProfileJsonFileData) error {
+		suite[profile.ID] = backendProfile{name: profile.Name, profileTypeID: profile.ProfileTypeID, hash: hashProfileAttributes(profile.Attributes)}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Diff" + strconv.Itoa(int(time.Now().Unix()))
+
+	output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), diffResultTable)
+	if err != nil {
+		return err
+	}
+	defer output.Abort()
+
+	counts := map[string]int{}
+	write := func(result diffResult) error {
+		counts[result.Result]++
+		return output.Write(result)
+	}
+
+	seen := map[string]bool{}
+	serviceTotal, err := pull("profile_service", func(profile ProfileJsonFileData) error {
+		seen[profile.ID] = true
+
+		suiteProfile, found := suite[profile.ID]
+		switch {
+		case !found:
+			return write(diffResult{ID: profile.ID, Name: profile.Name, ProfileTypeID: profile.ProfileTypeID, Result: "missing_from_suite"})
+		case hashAttributes && suiteProfile.hash != hashProfileAttributes(profile.Attributes):
+			return write(diffResult{ID: profile.ID, Name: profile.Name, ProfileTypeID: profile.ProfileTypeID, Result: "attributes_differ"})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	missing := maps.Keys(suite)
+	sort.Strings(missing)
+	for _, id := range missing {
+		if seen[id] {
+			continue
+		}
+		profile := suite[id]
+		if err := write(diffResult{ID: id, Name: profile.name, ProfileTypeID: profile.profileTypeID, Result: "missing_from_profile_service"}); err != nil {
+			return err
+		}
+	}
+
+	if err := output.Finish(); err != nil {
+		return err
+	}
+
+	currentEnv := configs.GetCurrentEnvironment()
+	printDiffTable([][]string{
+		{currentEnv, "suite", strconv.Itoa(suiteTotal)},
+		{currentEnv, "profile_service", strconv.Itoa(serviceTotal)},
+	})
+
+	fmt.Println()
+	fmt.Println(counts["missing_from_suite"], "profile(s) missing from suite,", counts["missing_from_profile_service"], "missing from profile_service")
+	if hashAttributes {
+		fmt.Println(counts["attributes_differ"], "profile(s) with different attributes")
+	}
+	fmt.Println("Results stored in " + outputLoc)
+
+	return nil
+}
+
+// hashProfileAttributes hashes the attributes of a profile in UID order, so the same values always give the same hash
+func hashProfileAttributes(attributes map[string]string) uint64 {
+	keys := maps.Keys(attributes)
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(attributes[key]))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
@@ -4,13 +4,11 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package profiles
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"nerm/cmd/configs"
 	"nerm/cmd/utilities"
 	"net/url"
-	"reflect"
 	"strconv"
 	"time"
 
@@ -22,7 +20,7 @@ func newProfileGetCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "get",
 		Short:   "Pulls Profiles from current environment",
-		Long:    "Pulls Profiles from current environment based on query parameters. Stores data in a CSV and JSON file at the defaul output location",
+		Long:    "Pulls Profiles from current environment based on query parameters. Stores data at the defaul output location in the formats set with --format (JSON and CSV by default)",
 		Example: "nerm profiles get --profile_type",
 		Aliases: []string{"g"},
 		PreRun: func(cmd *cobra.Command, args []string) {
@@ -33,6 +31,11 @@ func newProfileGetCommand() *cobra.Command {
 
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			id := cmd.Flags().Lookup("id").Value.String()
 			exclude := cmd.Flags().Lookup("exclude").Value.String()
 			profile_type := cmd.Flags().Lookup("profile_type").Value.String()
@@ -44,23 +47,34 @@ func newProfileGetCommand() *cobra.Command {
 			after_id := cmd.Flags().Lookup("after_id").Value.String()
 			isafterIdSet := cmd.Flags().Lookup("after_id").Changed
 			keep_archived, _ := cmd.Flags().GetBool("keep_archived")
+			resume := cmd.Flags().Lookup("resume").Value.String()
 
-			limitInt, _ := strconv.Atoi(limit)
+			limitInt, err := strconv.Atoi(limit)
+			if err != nil {
+				return utilities.ValidationErrorf("%q is not a valid number for --limit", limit)
+			}
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
 
 			var getLimitInt int
 
 			if getLimit != "" {
-				getLimitInt, _ = strconv.Atoi(getLimit)
+				getLimitInt, err = strconv.Atoi(getLimit)
+				if err != nil {
+					return utilities.ValidationErrorf("%q is not a valid number for --get_limit", getLimit)
+				}
 			} else {
 				getLimitInt = math.MaxInt
 			}
 
 			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Export" + strconv.Itoa(int(time.Now().Unix()))
 
-			var resp []byte
-			var requestErr error
-
-			createProfilesJsonFile(outputLoc + ".json")
+			var checkpoint *utilities.Checkpoint
+			var output *utilities.Exporter[ProfileJsonFileData]
+			startOffset := 0
 
 			params := url.Values{}
 			params.Add("metadata", "true") // always include metadata for limit/offsets
@@ -69,6 +83,11 @@ func newProfileGetCommand() *cobra.Command {
 			if exclude != "" {
 				params.Add("exclude_attributes", exclude)
 			}
+			profile_type, err = utilities.ResolveID(cmd.Context(), client, utilities.ProfileTypes, profile_type)
+			if err != nil {
+				return err
+			}
+
 			if profile_type != "" {
 				params.Add("profile_type_id", profile_type)
 			}
@@ -83,115 +102,121 @@ func newProfileGetCommand() *cobra.Command {
 			}
 			if limitInt > 500 {
 				fmt.Println("Limit can not be over 500")
-				limit = "500"
-				params.Add("limit", "500")
-			} else if limitInt > getLimitInt {
-				params.Add("limit", getLimit)
+				limitInt = 500
 			}
-			if isafterIdSet {
-				params.Add("after_id", after_id)
-			}
-
-			// make first call to get the total number of profiles to be returned
-			resp, requestErr = utilities.MakeAPIRequests("get", "profiles", id, params.Encode(), nil)
-
-			utilities.CheckError(requestErr)
 
-			var profile_result ProfileResponse
-			var respMetaData ResponseMetaData
+			pager := utilities.NewPaginator[ProfileJsonFileData](client, "profiles", "profiles", params)
+			pager.ID = id
+			pager.UseAfterID = isafterIdSet
+			pager.AfterID = after_id
 
-			err := json.Unmarshal(resp, &profile_result)
-			utilities.CheckError(err)
-
-			err = json.Unmarshal(resp, &respMetaData)
-			utilities.CheckError(err)
+			if resume != "" {
+				// carry on from where a previous run stopped, with the same environment, parameters and files
+				checkpoint, err = utilities.LoadCheckpoint(resume, "profiles get")
+				if err != nil {
+					return err
+				}
 
-			if getLimitInt > respMetaData.Metadata.Total {
-				// getLimit = strconv.Itoa(respMetaData.Metadata.Total)
-				getLimitInt = respMetaData.Metadata.Total
-			}
+				resumeClient, clientErr := utilities.NewClientForEnvironment(checkpoint.Environment)
+				if clientErr != nil {
+					return clientErr
+				}
+				pager.Client = resumeClient
 
-			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of profile types found
-			lastLoop := false                              // used to determine where to add commas inthe json file, and for progressbar
+				pager.Params, err = url.ParseQuery(checkpoint.Params)
+				if err != nil {
+					return utilities.ValidationErrorf("reading checkpoint %s: %w", resume, err)
+				}
+				pager.ID = checkpoint.Flags["id"]
+				limitInt, _ = strconv.Atoi(checkpoint.Flags["limit"])
+				keep_archived = checkpoint.Flags["keep_archived"] == "true"
+				isafterIdSet = checkpoint.Flags["after_id"] == "true"
+				pager.UseAfterID = isafterIdSet
+				pager.AfterID = checkpoint.AfterID
+				getLimitInt = checkpoint.GetLimit
+				outputLoc = checkpoint.OutputLoc
+				startOffset = checkpoint.Offset
+
+				output, err = utilities.ResumeExporter(checkpoint, profileTable)
+				if err != nil {
+					return err
+				}
+			} else {
+				// make first call to get the total number of profiles to be returned
+				total, requestErr := pager.Total(cmd.Context())
+				if requestErr != nil {
+					return requestErr
+				}
 
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
+				if getLimitInt > total {
+					getLimitInt = total
+				}
 
-				if !isafterIdSet {
-					params.Add("offset", strconv.Itoa(offset))
+				output, err = utilities.NewExporter(outputLoc, configs.GetOutputFormats(), profileTable)
+				if err != nil {
+					return err
 				}
 
-				resp, requestErr = utilities.MakeAPIRequests("get", "profiles", id, params.Encode(), nil)
-
-				utilities.CheckError(requestErr)
-
-				var profile_result ProfileResponse
-				var profile_filtered_result []struct {
-					ID               string            `json:"id"`
-					UID              string            `json:"uid"`
-					Name             string            `json:"name"`
-					ProfileTypeID    string            `json:"profile_type_id"`
-					Status           string            `json:"status"`
-					IDProofingStatus string            `json:"id_proofing_status"`
-					Archived         bool              `json:"archived"`
-					UpdatedAt        string            `json:"updated_at"`
-					CreatedAt        string            `json:"created_at"`
-					Attributes       map[string]string `json:"attributes"`
+				checkpoint = utilities.NewCheckpoint("profiles get", configs.GetCurrentEnvironment(), outputLoc)
+				checkpoint.Formats = output.Formats
+				checkpoint.Params = params.Encode()
+				checkpoint.AfterID = after_id
+				checkpoint.GetLimit = getLimitInt
+				checkpoint.Flags["id"] = id
+				checkpoint.Flags["limit"] = strconv.Itoa(limitInt)
+				checkpoint.Flags["keep_archived"] = strconv.FormatBool(keep_archived)
+				checkpoint.Flags["after_id"] = strconv.FormatBool(isafterIdSet)
+
+				if err := checkpoint.Advance(0, after_id, output); err != nil {
+					output.Close()
+					return err
 				}
-				// var profileResultZero ProfileResponse
-				var respMetaData ResponseMetaData
+			}
+			defer output.Close() // leaves valid JSON files, even when the export stops early
 
-				err := json.Unmarshal(resp, &profile_result)
-				utilities.CheckError(err)
+			if isafterIdSet && concurrency > 1 {
+				// each after_id comes from the page before it, so those pages can only be fetched one at a time
+				fmt.Println("--concurrency is ignored when using after_id pagination")
+			}
 
-				err = json.Unmarshal(resp, &respMetaData)
-				utilities.CheckError(err)
+			pager.Limit = limitInt
+			pager.GetLimit = getLimitInt
+			pager.Offset = startOffset
+			pager.Concurrency = concurrency
 
-				if reflect.DeepEqual(profile_result, ProfileResponse{}) {
-					fmt.Println("\n\nNo more Profiles found!")
-					break
-				}
+			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of profile types found
+			bar.Set(startOffset)
 
+			err = pager.Pages(cmd.Context(), func(page utilities.Page[ProfileJsonFileData]) error {
 				// Use a loop to check for and only store Archived or Non-Archived proifles (depending on the flag set)
 				// If both the record and the flag are true or both are false - store the profile
-				for _, rec := range profile_result.Profiles {
-					if rec.Archived && keep_archived {
-						profile_filtered_result = append(profile_filtered_result, rec)
-					} else if !rec.Archived && !keep_archived {
-						profile_filtered_result = append(profile_filtered_result, rec)
+				for _, rec := range page.Records {
+					if rec.Archived == keep_archived {
+						if err := output.Write(rec); err != nil {
+							return err
+						}
 					}
 				}
-				profile_result.Profiles = profile_filtered_result
-
-				if (offset + limitInt) >= getLimitInt {
-					bar.Set(getLimitInt)
-					lastLoop = true
-				}
 
-				if lastLoop {
+				if page.Last {
 					bar.Set(getLimitInt)
 				} else {
-					bar.Add(limitInt) // increment progress
-				}
-
-				if isafterIdSet {
-					if respMetaData.Metadata.AfterID == "null" || respMetaData.Metadata.AfterID == "" { // incase the metadata is broken, just use the last id
-						e, eErr := strconv.Atoi(limit)
-						utilities.CheckError(eErr)
-						params.Add("after_id", profile_result.Profiles[e-1].ID)
-					} else {
-						params.Add("after_id", respMetaData.Metadata.AfterID) // use the metadata value when possible
-					}
+					bar.Add(len(page.Records)) // increment progress
 				}
 
-				printJsonToFile(outputLoc+".json", profile_result, lastLoop)
+				return checkpoint.Advance(page.NextOffset, page.NextAfterID, output)
+			})
+			if err != nil {
+				return checkpoint.Failed(err)
 			}
 
-			// jsonData, _ := json.MarshalIndent(profile_result, "", "    ")
-			// fmt.Println(string(jsonData))
-
-			endProfilesJsonFile(outputLoc + ".json")
+			if err := output.Finish(); err != nil {
+				return err
+			}
 
-			convertJSONToCSV(outputLoc+".json", outputLoc+".csv")
+			if err := checkpoint.Remove(); err != nil {
+				return err
+			}
 
 			fmt.Println("\n" + "Profile data stored in " + outputLoc)
 
@@ -200,7 +225,7 @@ func newProfileGetCommand() *cobra.Command {
 	}
 	cmd.Flags().StringP("id", "i", "", "ID of a specific Profile")
 	cmd.Flags().StringP("exclude", "e", "", "Exclude attributes from response")
-	cmd.Flags().StringP("profile_type", "t", "", "Profile type ID of profiles")
+	cmd.Flags().StringP("profile_type", "t", "", "Profile type of profiles (ID, UID or name)")
 	cmd.Flags().StringP("status", "s", "", "Status of profiles")
 	cmd.Flags().StringP("name", "n", "", "Name of the profile(s) to look for")
 	cmd.Flags().StringP("force_backend", "b", "", "Force the Profile Service or Identity Suite controllers")
@@ -208,6 +233,10 @@ func newProfileGetCommand() *cobra.Command {
 	cmd.Flags().StringP("get_limit", "g", "", "Set a Get limit for how many profiles to pull back (default is All profiles)")
 	cmd.Flags().String("after_id", "", "Get all Profiles using the after_id pagination. Leave blank or add a value to start from")
 	cmd.Flags().Bool("keep_archived", false, "When using after_id pagination, determin if you want to store records that are archived or not. Requried if using after_id")
+	cmd.Flags().String("concurrency", "1", "Number of pages to fetch at the same time (offset pagination only)")
+	cmd.Flags().String("resume", "", "Checkpoint file of a failed export to carry on from (stored next to the export as .checkpoint.json)")
+
+	cmd.RegisterFlagCompletionFunc("profile_type", utilities.CompleteNames(utilities.ProfileTypes))
 
 	return cmd
 }
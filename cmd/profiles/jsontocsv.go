@@ -4,6 +4,9 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package profiles
 
 import (
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -13,13 +16,20 @@ func newProfileJSONtoCSVCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "convert",
 		Short:   "Converts a JSON file of Profile data to a CSV",
-		Long:    "Converts a JSON file of Profile data to a CSV",
-		Example: "nerm profiles convert -f something.json",
+		Long:    "Converts a JSON or JSON Lines file of Profile data to a CSV, or to the formats given with --format",
+		Example: "nerm profiles convert -f something.json | nerm profiles convert -f something.jsonl --format xlsx,parquet",
 		Aliases: []string{"d"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			file := cmd.Flags().Lookup("file").Value.String()
 
-			convertJSONToCSV(file, strings.Replace(file, "json", "csv", 1))
+			formats := []string{utilities.FormatCSV}
+			if cmd.Flags().Changed("format") {
+				formats = configs.GetOutputFormats()
+			}
+
+			if err := utilities.ConvertJSONFile(file, strings.TrimSuffix(file, filepath.Ext(file)), formats, profileTable); err != nil {
+				return err
+			}
 
 			return nil
 		},
@@ -4,11 +4,9 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package profiles
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"nerm/cmd/utilities"
-	"os"
-	"slices"
 	"strings"
 
 	"github.com/fatih/color"
@@ -16,28 +14,18 @@ import (
 	"github.com/spf13/cobra"
 )
 
-type ProfileTypeResponse struct {
-	ProfileTypes []struct {
-		Name     string `json:"name"`
-		ID       string `json:"id"`
-		UID      string `json:"uid"`
-		Category string `json:"category"`
-	} `json:"profile_types"`
+// ProfileResponse is a list of profiles. profiles/<id> can return the profile on its own instead
+type ProfileResponse struct {
+	Profile  *ProfileJsonFileData  `json:"profile,omitempty"`
+	Profiles []ProfileJsonFileData `json:"profiles"`
 }
 
-type ProfileResponse struct {
-	Profiles []struct {
-		ID               string            `json:"id"`
-		UID              string            `json:"uid"`
-		Name             string            `json:"name"`
-		ProfileTypeID    string            `json:"profile_type_id"`
-		Status           string            `json:"status"`
-		IDProofingStatus string            `json:"id_proofing_status"`
-		Archived         bool              `json:"archived"`
-		UpdatedAt        string            `json:"updated_at"`
-		CreatedAt        string            `json:"created_at"`
-		Attributes       map[string]string `json:"attributes"`
-	} `json:"profiles"`
+// all returns the profile and the list of profiles of a response together
+func (r ProfileResponse) all() []ProfileJsonFileData {
+	if r.Profile == nil {
+		return r.Profiles
+	}
+	return append([]ProfileJsonFileData{*r.Profile}, r.Profiles...)
 }
 
 type ProfileJsonFileData struct {
@@ -47,6 +35,7 @@ type ProfileJsonFileData struct {
 	ProfileTypeID    string            `json:"profile_type_id"`
 	Status           string            `json:"status"`
 	IDProofingStatus string            `json:"id_proofing_status"`
+	Archived         bool              `json:"archived"`
 	UpdatedAt        string            `json:"updated_at"`
 	CreatedAt        string            `json:"created_at"`
 	Attributes       map[string]string `json:"attributes"`
@@ -62,6 +51,27 @@ type ResponseMetaData struct {
 	} `json:"_metadata"`
 }
 
+// findProfile pulls the profile out of a profiles/<id> or profiles search response. UIDs are checked, since a search
+// by UID can return other profiles
+func findProfile(resp []byte, id string, uid string) (ProfileJsonFileData, bool, error) {
+	var result ProfileResponse
+	if len(resp) > 0 {
+		if err := json.Unmarshal(resp, &result); err != nil {
+			return ProfileJsonFileData{}, false, fmt.Errorf("can not unmarshal profiles: %w", err)
+		}
+	}
+
+	for _, profile := range result.all() {
+		if profile.ID == "" {
+			continue
+		}
+		if (id != "" && profile.ID == id) || (id == "" && profile.UID == uid) {
+			return profile, true, nil
+		}
+	}
+	return ProfileJsonFileData{}, false, nil
+}
+
 func NewProfilesCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "profiles",
@@ -79,6 +89,12 @@ func NewProfilesCommand() *cobra.Command {
 		newProfileGetCommand(),
 		newProfileDiffCommand(),
 		newProfileJSONtoCSVCommand(),
+		newProfileCreateCommand(),
+		newProfileUpdateCommand(),
+		newProfileDeleteCommand(),
+		newProfileRestoreCommand(),
+		newProfileCompareCommand(),
+		newProfileCompareEnvCommand(),
 	)
 
 	return cmd
@@ -112,94 +128,111 @@ func printDiffTable(data [][]string) {
 	tbl.Print()
 }
 
-func createProfilesJsonFile(fileLoc string) {
+func printChangeTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	file, _ := os.OpenFile(fileLoc, os.O_CREATE|os.O_TRUNC, os.ModePerm)
-	defer file.Close()
-	// file.WriteString(strings.Trim("{\"profiles\":[", "\""))
-	file.WriteString(strings.Trim("[", "\""))
-	defer file.Close()
-}
+	tbl := table.New("Profile", "Attribute", "Current", "New")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-func endProfilesJsonFile(fileLoc string) {
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3])
+	}
 
-	file, _ := os.OpenFile(fileLoc, os.O_APPEND, os.ModePerm)
-	defer file.Close()
-	file.WriteString(strings.Trim("]", "\""))
-	defer file.Close()
+	tbl.Print()
 }
 
-func printJsonToFile(fileLoc string, jsonData ProfileResponse, lastLoop bool) {
+func printProfileSampleTable(profiles []ProfileJsonFileData) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	file, _ := os.OpenFile(fileLoc, os.O_APPEND|os.O_CREATE, os.ModePerm)
-	defer file.Close()
-	encoder := json.NewEncoder(file)
+	tbl := table.New("Name", "ID", "Profile Type", "Status")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for i, rec := range jsonData.Profiles {
-		encoder.Encode(rec)
-		if !lastLoop {
-			file.WriteString(strings.Trim(",", "\""))
-		} else if (i + 1) != len(jsonData.Profiles) {
-			file.WriteString(strings.Trim(",", "\""))
-		}
+	for _, profile := range profiles {
+		tbl.AddRow(profile.Name, profile.ID, profile.ProfileTypeID, profile.Status)
 	}
-	// encoder := json.NewEncoder(file)
-	// encoder.Encode(jsonData)
-	defer file.Close()
+
+	tbl.Print()
 }
 
-func convertJSONToCSV(source string, destination string) error {
-	var keys []string
+// profileStatuses are the statuses a profile can be set to
+var profileStatuses = []string{"Active", "Inactive", "On Leave", "Terminated"}
 
-	// Read the JSON file into the struct array
-	sourceFile, err := os.Open(source)
-	utilities.CheckError(err)
+// attributeLookup finds the attributes of a profile type by UID or label
+type attributeLookup struct {
+	byUID   map[string]utilities.Attribute
+	byLabel map[string][]utilities.Attribute
+}
 
-	defer sourceFile.Close()
+func newAttributeLookup(attributes []utilities.Attribute) *attributeLookup {
+	lookup := &attributeLookup{byUID: map[string]utilities.Attribute{}, byLabel: map[string][]utilities.Attribute{}}
+	for _, attribute := range attributes {
+		lookup.byUID[attribute.UID] = attribute
+		label := strings.ToLower(attribute.Label)
+		lookup.byLabel[label] = append(lookup.byLabel[label], attribute)
+	}
+	return lookup
+}
 
-	var profileData []ProfileJsonFileData
-	if err := json.NewDecoder(sourceFile).Decode(&profileData); err != nil {
-		return err
+// find matches a column to an attribute by UID first, then by label (ignoring case).
+// ambiguous is set when more than one attribute uses the label
+func (l *attributeLookup) find(column string) (attribute utilities.Attribute, found bool, ambiguous bool) {
+	if attribute, found := l.byUID[column]; found {
+		return attribute, true, false
 	}
 
-	for _, r := range profileData {
-		for k := range r.Attributes {
-			keys = append(keys, k)
-		}
+	switch matches := l.byLabel[strings.ToLower(column)]; len(matches) {
+	case 0:
+		return utilities.Attribute{}, false, false
+	case 1:
+		return matches[0], true, false
 	}
+	return utilities.Attribute{}, false, true
+}
 
-	slices.Sort(keys)           // sort a-z
-	keys = slices.Compact(keys) // remove duplicates
+// matchProfileStatus finds the status a value stands for, ignoring case (ie "on leave" is "On Leave")
+func matchProfileStatus(value string) (string, bool) {
+	for _, status := range profileStatuses {
+		if strings.EqualFold(strings.TrimSpace(value), status) {
+			return status, true
+		}
+	}
+	return value, false
+}
 
-	// Create a new file to store CSV data
-	outputFile, err := os.Create(destination)
-	utilities.CheckError(err)
+// profileTable flattens profiles for the csv, tsv, xlsx and parquet exports. Each attribute gets its own column
+var profileTable = utilities.Table[ProfileJsonFileData]{
+	Headers: []string{"ID", "UID", "Name", "ProfileTypeID", "Status", "IDProofingStatus", "UpdatedAt", "CreatedAt"},
+	Row: func(r ProfileJsonFileData) ([]string, map[string]string) {
+		return []string{r.ID, r.UID, r.Name, r.ProfileTypeID, r.Status, r.IDProofingStatus, r.UpdatedAt, r.CreatedAt}, r.Attributes
+	},
+}
 
-	defer outputFile.Close()
+func printCompareTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	// Write the header of the CSV file and the successive rows by iterating through the JSON struct array
-	writer := csv.NewWriter(outputFile)
-	defer writer.Flush()
+	tbl := table.New("Profile Type", "Added", "Removed", "Status Changed", "Attributes Changed", "Unchanged")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	header := []string{"ID", "UID", "Name", "ProfileTypeID", "Status", "IDProofingStatus", "UpdatedAt", "CreatedAt"}
-	// for _, k := range keys {
-	// 	header = append(header, k)
-	// }
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4], row[5])
+	}
 
-	header = append(header, keys...)
+	tbl.Print()
+}
 
-	err = writer.Write(header)
-	utilities.CheckError(err)
+func printEnvCompareTable(data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	for _, r := range profileData {
-		var csvRow []string
-		csvRow = append(csvRow, r.ID, r.UID, r.Name, r.ProfileTypeID, r.Status, r.IDProofingStatus, r.UpdatedAt, r.CreatedAt)
+	tbl := table.New("Profile Type", "Matched", "Mismatched", "Missing", "Extra", "Duplicate")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-		for j := 7; j < len(header); j++ {
-			csvRow = append(csvRow, r.Attributes[header[j]])
-		}
-		err = writer.Write(csvRow)
-		utilities.CheckError(err)
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3], row[4], row[5])
 	}
-	return nil
+
+	tbl.Print()
 }
@@ -0,0 +1,262 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+func newProfileRestoreCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "restore",
+		Short:   "Restores Profiles from an export",
+		Long:    "Compares each profile of a JSON or JSON Lines export (ie from 'profiles get' or the undo file of 'profiles delete') with the profile as it is now, and puts back the attributes and status that changed since the export. Profiles that were archived since the export (ie by 'profiles delete') are unarchived. Only shows what would change unless --apply is set. Profiles that no longer exist are reported as conflicts",
+		Example: "nerm profiles restore -f export.json | nerm profiles restore -f export.json --only-attributes email,manager --apply",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			file := cmd.Flags().Lookup("file").Value.String()
+			onlyAttributes := cmd.Flags().Lookup("only-attributes").Value.String()
+			apply, _ := cmd.Flags().GetBool("apply")
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			snapshots, err := utilities.ReadJSONFile[ProfileJsonFileData](file)
+			if err != nil {
+				return utilities.ValidationErrorf("reading %s: %w", file, err)
+			}
+			if len(snapshots) == 0 {
+				return utilities.ValidationErrorf("%s has no profiles to restore", file)
+			}
+
+			var filter []string
+			for _, attribute := range strings.Split(onlyAttributes, ",") {
+				if attribute = strings.TrimSpace(attribute); attribute != "" {
+					filter = append(filter, attribute)
+				}
+			}
+
+			updates := make([]profileUpdate, len(snapshots))
+			fetchErrs := make([]error, len(snapshots))
+			filters := map[string]*restoreFilter{} // by profile type ID
+			matched := map[string]bool{}           // --only-attributes values that matched an attribute
+
+			fmt.Println("Pulling", len(snapshots), "profile(s)")
+			bar := progressbar.Default(int64(len(snapshots)))
+
+			fetchProfile := func(ctx context.Context, i int) ([]byte, error) {
+				resp, err := client.WithContext(ctx).Get("profiles", snapshots[i].ID, "")
+				if err != nil && ctx.Err() == nil {
+					fetchErrs[i] = err // recorded against the profile instead of stopping the command
+					return nil, nil
+				}
+				return resp, err
+			}
+
+			handleProfile := func(i int, resp []byte) (bool, error) {
+				bar.Add(1)
+
+				snapshot := snapshots[i]
+				update := &updates[i]
+				update.result = updateResult{Row: i + 1, ID: snapshot.ID, UID: snapshot.UID, Name: snapshot.Name}
+
+				if err := fetchErrs[i]; err != nil {
+					if utilities.IsNotFound(err) {
+						update.result.Result, update.result.Error = "conflict", "the profile no longer exists"
+					} else {
+						update.result.Result, update.result.Error = "failed", utilities.RowError(err)
+					}
+					return true, nil
+				}
+
+				live, found, err := findProfile(resp, snapshot.ID, "")
+				if err != nil {
+					return false, err
+				}
+				if !found {
+					update.result.Result, update.result.Error = "conflict", "the profile no longer exists"
+					return true, nil
+				}
+
+				typeFilter, ok := filters[live.ProfileTypeID]
+				if !ok {
+					typeFilter, err = newRestoreFilter(cmd.Context(), client, live.ProfileTypeID, filter, matched)
+					if err != nil {
+						return false, err
+					}
+					filters[live.ProfileTypeID] = typeFilter
+				}
+
+				planRestore(update, snapshot, live, typeFilter)
+				return true, nil
+			}
+
+			if err := utilities.FetchPages(cmd.Context(), concurrency, 0, len(snapshots), 1, fetchProfile, handleProfile); err != nil {
+				return err
+			}
+
+			var unmatched []string
+			for _, attribute := range filter {
+				if !matched[attribute] {
+					unmatched = append(unmatched, attribute)
+				}
+			}
+			if len(unmatched) > 0 {
+				fmt.Println("Not an attribute of any of the profiles:", strings.Join(unmatched, ", "))
+			}
+
+			counts := printUpdateChanges(updates)
+			fmt.Println(counts["would_restore"], "profile(s) to restore,", counts["unchanged"], "unchanged,", counts["conflict"], "no longer exist and", counts["failed"], "could not be pulled")
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Restore" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), updateResultTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			var toPatch []int
+			for i, update := range updates {
+				if update.result.Result == "would_restore" && apply {
+					toPatch = append(toPatch, i)
+					continue
+				}
+				if err := output.Write(update.result); err != nil {
+					return err
+				}
+			}
+
+			if !apply {
+				if err := output.Finish(); err != nil {
+					return err
+				}
+				fmt.Println("Dry run - nothing was changed. Run again with --apply to restore these profiles. Report stored in " + outputLoc)
+				return nil
+			}
+
+			if err := patchProfiles(cmd.Context(), client, updates, toPatch, concurrency, "restored", output); err != nil {
+				return err
+			}
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			fmt.Println("\nRestored", countResults(updates, "restored"), "profile(s). Report stored in "+outputLoc)
+
+			if failed := countResults(updates, "failed"); failed > 0 {
+				return fmt.Errorf("%d of %d profile(s) could not be restored. See %s for the errors", failed, len(snapshots), outputLoc)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("file", "f", "", "JSON or JSON Lines export of the profiles to restore")
+	cmd.Flags().String("only-attributes", "", "Only restore these attributes (UIDs or labels, separated by commas). Add 'status' or 'archived' to restore the status or unarchive profiles too")
+	cmd.Flags().Bool("apply", false, "Restore the profiles. Without it nothing is changed")
+	cmd.Flags().String("concurrency", "1", "Number of profiles to pull or restore at the same time")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// restoreFilter is what gets restored on the profiles of one profile type
+type restoreFilter struct {
+	all        bool            // no --only-attributes filter was given
+	status     bool            // restore the status
+	archived   bool            // unarchive profiles that were not archived in the export
+	attributes map[string]bool // attribute UIDs to restore
+}
+
+// newRestoreFilter matches the --only-attributes values to the attributes of a profile type. Values that
+// match are added to matched
+func newRestoreFilter(ctx context.Context, client *utilities.Client, profileTypeID string, filter []string, matched map[string]bool) (*restoreFilter, error) {
+	if len(filter) == 0 {
+		return &restoreFilter{all: true, status: true, archived: true}, nil
+	}
+
+	attributes, err := utilities.ListAttributes(ctx, client, profileTypeID)
+	if err != nil {
+		return nil, err
+	}
+	lookup := newAttributeLookup(attributes)
+
+	typeFilter := &restoreFilter{attributes: map[string]bool{}}
+	for _, value := range filter {
+		if strings.EqualFold(value, "status") {
+			typeFilter.status = true
+			matched[value] = true
+			continue
+		}
+		if strings.EqualFold(value, "archived") {
+			typeFilter.archived = true
+			matched[value] = true
+			continue
+		}
+
+		if attribute, found, _ := lookup.find(value); found {
+			typeFilter.attributes[attribute.UID] = true
+			matched[value] = true
+		}
+	}
+
+	return typeFilter, nil
+}
+
+// planRestore works out what needs to change for a profile to match its export. Only attributes that
+// are in the export are restored, so attributes added since it was taken are left alone
+func planRestore(update *profileUpdate, snapshot ProfileJsonFileData, live ProfileJsonFileData, filter *restoreFilter) {
+	update.attributes = map[string]string{}
+	update.current = map[string]string{}
+	update.result.Changes = map[string]string{}
+	update.result.UID, update.result.Name = live.UID, live.Name
+
+	if filter.status && snapshot.Status != "" && snapshot.Status != live.Status {
+		update.status = snapshot.Status
+		update.current["status"] = live.Status
+		update.result.Changes["status"] = live.Status + " -> " + snapshot.Status
+	}
+
+	// only unarchiving is restored. Profiles archived in the export are left as they are now
+	if filter.archived && !snapshot.Archived && live.Archived {
+		archived := false
+		update.archived = &archived
+		update.current["archived"] = "true"
+		update.result.Changes["archived"] = "true -> false"
+	}
+
+	for uid, value := range snapshot.Attributes {
+		if !filter.all && !filter.attributes[uid] {
+			continue
+		}
+
+		if current := live.Attributes[uid]; current != value {
+			update.attributes[uid] = value
+			update.current[uid] = current
+			update.result.Changes[uid] = current + " -> " + value
+		}
+	}
+
+	if len(update.result.Changes) == 0 {
+		update.result.Result = "unchanged"
+	} else {
+		update.result.Result = "would_restore"
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestPlanRestore(t *testing.T) {
+	all := &restoreFilter{all: true, status: true, archived: true}
+
+	tests := []struct {
+		name     string
+		snapshot func(p *ProfileJsonFileData)
+		live     func(p *ProfileJsonFileData)
+		filter   *restoreFilter
+		result   string
+		changes  map[string]string
+		status   string
+		archived bool // the profile is unarchived
+	}{
+		{
+			name:    "nothing changed",
+			filter:  all,
+			result:  "unchanged",
+			changes: map[string]string{},
+		},
+		{
+			name:    "attribute and status",
+			live:    func(p *ProfileJsonFileData) { p.Status, p.Attributes["title"] = "Terminated", "Manager" },
+			filter:  all,
+			result:  "would_restore",
+			changes: map[string]string{"status": "Terminated -> Active", "title": "Manager -> Engineer"},
+			status:  "Active",
+		},
+		{
+			name:    "attributes added since the export are left alone",
+			live:    func(p *ProfileJsonFileData) { p.Attributes["manager"] = "p9" },
+			filter:  all,
+			result:  "unchanged",
+			changes: map[string]string{},
+		},
+		{
+			name:     "archived since the export",
+			live:     func(p *ProfileJsonFileData) { p.Archived = true },
+			filter:   all,
+			result:   "would_restore",
+			changes:  map[string]string{"archived": "true -> false"},
+			archived: true,
+		},
+		{
+			name:     "archived in the export",
+			snapshot: func(p *ProfileJsonFileData) { p.Archived = true },
+			filter:   all,
+			result:   "unchanged",
+			changes:  map[string]string{},
+		},
+		{
+			name: "only attributes",
+			live: func(p *ProfileJsonFileData) {
+				p.Status, p.Archived, p.Attributes["title"] = "Terminated", true, "Manager"
+			},
+			filter:  &restoreFilter{attributes: map[string]bool{"title": true}},
+			result:  "would_restore",
+			changes: map[string]string{"title": "Manager -> Engineer"},
+		},
+		{
+			name: "only status",
+			live: func(p *ProfileJsonFileData) {
+				p.Status, p.Archived, p.Attributes["title"] = "Terminated", true, "Manager"
+			},
+			filter:  &restoreFilter{status: true, attributes: map[string]bool{}},
+			result:  "would_restore",
+			changes: map[string]string{"status": "Terminated -> Active"},
+			status:  "Active",
+		},
+		{
+			name: "only archived",
+			live: func(p *ProfileJsonFileData) {
+				p.Status, p.Archived, p.Attributes["title"] = "Terminated", true, "Manager"
+			},
+			filter:   &restoreFilter{archived: true, attributes: map[string]bool{}},
+			result:   "would_restore",
+			changes:  map[string]string{"archived": "true -> false"},
+			archived: true,
+		},
+		{
+			name:    "filtered out",
+			live:    func(p *ProfileJsonFileData) { p.Attributes["email"] = "jane@new.example.com" },
+			filter:  &restoreFilter{attributes: map[string]bool{"title": true}},
+			result:  "unchanged",
+			changes: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, live := testProfile(), testProfile()
+			if test.snapshot != nil {
+				test.snapshot(&snapshot)
+			}
+			if test.live != nil {
+				test.live(&live)
+			}
+
+			var update profileUpdate
+			planRestore(&update, snapshot, live, test.filter)
+
+			if update.result.Result != test.result {
+				t.Errorf("result is %q, want %q", update.result.Result, test.result)
+			}
+			if !maps.Equal(update.result.Changes, test.changes) {
+				t.Errorf("changes are %v, want %v", update.result.Changes, test.changes)
+			}
+			if update.status != test.status {
+				t.Errorf("status is %q, want %q", update.status, test.status)
+			}
+			if unarchived := update.archived != nil && !*update.archived; unarchived != test.archived {
+				t.Errorf("unarchived is %v, want %v", unarchived, test.archived)
+			}
+		})
+	}
+}
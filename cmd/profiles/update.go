@@ -0,0 +1,437 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+// maxChangesShown is how many changes are printed before the rest are left to the report
+const maxChangesShown = 50
+
+// reportFields are columns of an update report. They are skipped so a report can be edited and used as the input file
+var reportFields = []string{"row", "result", "error"}
+
+var errNoProfileKey = errors.New("the row has no ID or UID")
+
+// updateResult is one row of the report written by 'profiles update'
+type updateResult struct {
+	Row     int               `json:"row"`
+	Result  string            `json:"result"` // updated, unchanged, failed, not_found or invalid. A dry run has would_update instead of updated
+	ID      string            `json:"id"`
+	UID     string            `json:"uid"`
+	Name    string            `json:"name"`
+	Error   string            `json:"error,omitempty"`
+	Changes map[string]string `json:"changes,omitempty"` // attribute UID (or status) -> "current -> new"
+}
+
+var updateResultTable = utilities.Table[updateResult]{
+	Headers: []string{"Row", "Result", "ID", "UID", "Name", "Error"},
+	Row: func(r updateResult) ([]string, map[string]string) {
+		return []string{strconv.Itoa(r.Row), r.Result, r.ID, r.UID, r.Name, r.Error}, r.Changes
+	},
+}
+
+// profileUpdate is a row of the input file matched to its profile, with what it changes
+type profileUpdate struct {
+	result     updateResult
+	status     string            // new status. Empty when the status does not change
+	archived   *bool             // new archived flag. nil when it does not change
+	attributes map[string]string // attribute UID -> new value, for the attributes that change
+	current    map[string]string // attribute UID (or status) -> current value, for the diff
+}
+
+type profilePatch struct {
+	Profile struct {
+		Status     string            `json:"status,omitempty"`
+		Archived   *bool             `json:"archived,omitempty"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	} `json:"profile"`
+}
+
+func newProfileUpdateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "update",
+		Short:   "Updates Profiles from a CSV or JSON file",
+		Long:    "Updates Profiles from a CSV, TSV, JSON or JSON Lines file. Rows are matched to profiles by an ID or UID column, and the other columns are matched to attributes by UID or label (a 'status' column changes the status). Each profile is pulled first to show what will change, and only the attributes that change are sent. Writes a report of every row, and a file of the rows that were not updated which can be fixed and run again",
+		Example: "nerm profiles update -f changes.csv --dry_run | nerm profiles update -f changes.csv",
+		Aliases: []string{"u"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			file := cmd.Flags().Lookup("file").Value.String()
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+			clearEmpty, _ := cmd.Flags().GetBool("clear_empty")
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			columns, rows, err := utilities.ReadInputFile(file)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return utilities.ValidationErrorf("%s has no rows to update profiles from", file)
+			}
+
+			idColumn, uidColumn := "", ""
+			for _, column := range columns {
+				if strings.EqualFold(column, "id") {
+					idColumn = column
+				} else if strings.EqualFold(column, "uid") {
+					uidColumn = column
+				}
+			}
+			if idColumn == "" && uidColumn == "" {
+				return utilities.ValidationErrorf("%s needs an ID or UID column to match its rows to profiles", file)
+			}
+
+			updates := make([]profileUpdate, len(rows))
+			fetchErrs := make([]error, len(rows))
+			lookups := map[string]*attributeLookup{} // by profile type ID
+
+			fmt.Println("Pulling", len(rows), "profile(s)")
+			bar := progressbar.Default(int64(len(rows)))
+
+			// pull the current state of every profile. Each "page" is one row of the file
+			fetchProfile := func(ctx context.Context, i int) ([]byte, error) {
+				c := client.WithContext(ctx)
+				id := strings.TrimSpace(rows[i].Values[idColumn])
+				uid := strings.TrimSpace(rows[i].Values[uidColumn])
+
+				var resp []byte
+				var err error
+				switch {
+				case id != "":
+					resp, err = c.Get("profiles", id, "")
+				case uid != "":
+					params := url.Values{}
+					params.Add("uid", uid)
+					resp, err = c.Get("profiles", "", params.Encode())
+				default:
+					err = errNoProfileKey
+				}
+
+				if err != nil && ctx.Err() == nil {
+					fetchErrs[i] = err // recorded against the row instead of stopping the command
+					return nil, nil
+				}
+				return resp, err
+			}
+
+			handleProfile := func(i int, resp []byte) (bool, error) {
+				bar.Add(1)
+
+				row := rows[i]
+				update := &updates[i]
+				update.result = updateResult{Row: row.Number, ID: strings.TrimSpace(row.Values[idColumn]), UID: strings.TrimSpace(row.Values[uidColumn])}
+
+				if err := fetchErrs[i]; err != nil {
+					switch {
+					case errors.Is(err, errNoProfileKey):
+						update.result.Result, update.result.Error = "invalid", err.Error()
+					case utilities.IsNotFound(err):
+						update.result.Result, update.result.Error = "not_found", "no profile has this ID"
+					default:
+						update.result.Result, update.result.Error = "failed", utilities.RowError(err)
+					}
+					return true, nil
+				}
+
+				profile, found, err := findProfile(resp, update.result.ID, update.result.UID)
+				if err != nil {
+					return false, err
+				}
+				if !found {
+					update.result.Result, update.result.Error = "not_found", "no profile has this ID or UID"
+					return true, nil
+				}
+				update.result.ID, update.result.UID, update.result.Name = profile.ID, profile.UID, profile.Name
+
+				lookup, ok := lookups[profile.ProfileTypeID]
+				if !ok {
+					attributes, err := utilities.ListAttributes(cmd.Context(), client, profile.ProfileTypeID)
+					if err != nil {
+						return false, err
+					}
+					lookup = newAttributeLookup(attributes)
+					lookups[profile.ProfileTypeID] = lookup
+				}
+
+				planUpdate(update, profile, row, columns, []string{idColumn, uidColumn}, lookup, clearEmpty)
+				return true, nil
+			}
+
+			if err := utilities.FetchPages(cmd.Context(), concurrency, 0, len(rows), 1, fetchProfile, handleProfile); err != nil {
+				return err
+			}
+
+			counts := printUpdateChanges(updates)
+			fmt.Println(counts["would_update"], "profile(s) to update,", counts["unchanged"], "unchanged,", counts["not_found"], "not found,", counts["invalid"], "not valid and", counts["failed"], "could not be pulled")
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Profile_Update" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), updateResultTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			var toPatch []int
+			for i, update := range updates {
+				if update.result.Result == "would_update" && !dryRun {
+					toPatch = append(toPatch, i)
+					continue
+				}
+				if err := output.Write(update.result); err != nil {
+					return err
+				}
+			}
+
+			if dryRun {
+				if err := output.Finish(); err != nil {
+					return err
+				}
+				fmt.Println("Dry run - nothing was changed. Report stored in " + outputLoc)
+				return nil
+			}
+
+			patchErr := patchProfiles(cmd.Context(), client, updates, toPatch, concurrency, "updated", output)
+
+			// keep the rows that did not go through, even if the command was stopped part way
+			var retry []utilities.InputRow
+			for i, update := range updates {
+				if update.result.Result != "updated" && update.result.Result != "unchanged" {
+					retry = append(retry, rows[i])
+				}
+			}
+			retryLoc := outputLoc + "_retry.csv"
+			if len(retry) > 0 {
+				if err := writeInputRows(retryLoc, columns, retry); err != nil {
+					return err
+				}
+			}
+
+			if patchErr != nil {
+				return patchErr
+			}
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			fmt.Println("\nUpdated", countResults(updates, "updated"), "profile(s). Report stored in "+outputLoc)
+
+			if len(retry) > 0 {
+				return fmt.Errorf("%d of %d row(s) were not updated. Fix them in %s and run it again", len(retry), len(rows), retryLoc)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("file", "f", "", "CSV, TSV, JSON or JSON Lines file with an ID or UID column and a column for each attribute to change")
+	cmd.Flags().Bool("dry_run", false, "Only show what would change")
+	cmd.Flags().Bool("clear_empty", false, "Clear attributes that are empty in the file. By default empty values are left as they are")
+	cmd.Flags().String("concurrency", "1", "Number of profiles to pull or update at the same time")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// planUpdate works out what a row changes on its profile
+func planUpdate(update *profileUpdate, profile ProfileJsonFileData, row utilities.InputRow, columns []string, keyColumns []string, lookup *attributeLookup, clearEmpty bool) {
+	update.attributes = map[string]string{}
+	update.current = map[string]string{}
+	update.result.Changes = map[string]string{}
+
+	var problems []string
+
+	for _, column := range columns {
+		lower := strings.ToLower(column)
+		if column == "" || slices.Contains(keyColumns, column) || slices.Contains(profileFields, lower) || slices.Contains(reportFields, lower) {
+			continue
+		}
+
+		value, ok := row.Values[column]
+		if !ok || (value == "" && !clearEmpty) {
+			continue
+		}
+
+		if lower == "status" {
+			status, ok := matchProfileStatus(value)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%q is not a valid status", value))
+			} else if status != profile.Status {
+				update.status = status
+				update.current["status"] = profile.Status
+				update.result.Changes["status"] = profile.Status + " -> " + status
+			}
+			continue
+		}
+
+		attribute, found, ambiguous := lookup.find(column)
+		switch {
+		case ambiguous:
+			problems = append(problems, column+" is the label of more than one attribute (use the UID instead)")
+			continue
+		case !found:
+			problems = append(problems, column+" is not an attribute of the profile's type")
+			continue
+		case attribute.Archived:
+			problems = append(problems, column+" is an archived attribute")
+			continue
+		}
+
+		if current := profile.Attributes[attribute.UID]; current != value {
+			update.attributes[attribute.UID] = value
+			update.current[attribute.UID] = current
+			update.result.Changes[attribute.UID] = current + " -> " + value
+		}
+	}
+
+	switch {
+	case len(problems) > 0:
+		update.result.Result = "invalid"
+		update.result.Error = strings.Join(problems, "; ")
+		update.result.Changes = nil // nothing is sent for a row with a problem
+	case len(update.result.Changes) == 0:
+		update.result.Result = "unchanged"
+	default:
+		update.result.Result = "would_update"
+	}
+}
+
+// printUpdateChanges prints a table of the changes to make, and returns how many profiles have each result
+func printUpdateChanges(updates []profileUpdate) map[string]int {
+	var changes [][]string
+	counts := map[string]int{}
+
+	for _, update := range updates {
+		counts[update.result.Result]++
+
+		keys := maps.Keys(update.result.Changes)
+		slices.Sort(keys)
+		for _, key := range keys {
+			newValue := update.attributes[key]
+			switch {
+			case key == "status":
+				newValue = update.status
+			case key == "archived" && update.archived != nil:
+				newValue = strconv.FormatBool(*update.archived)
+			}
+			changes = append(changes, []string{update.result.Name + " (" + update.result.ID + ")", key, update.current[key], newValue})
+		}
+	}
+
+	fmt.Println()
+	if len(changes) > 0 {
+		printChangeTable(changes[:min(len(changes), maxChangesShown)])
+		if len(changes) > maxChangesShown {
+			fmt.Println("...and", len(changes)-maxChangesShown, "more change(s). Every change is listed in the report")
+		}
+	}
+
+	return counts
+}
+
+// patchProfiles sends the changes of the updates at the toPatch indexes, then writes each result to the report.
+// done is the result of a profile that was changed (ie "updated")
+func patchProfiles(ctx context.Context, client *utilities.Client, updates []profileUpdate, toPatch []int, concurrency int, done string, output *utilities.Exporter[updateResult]) error {
+	patchErrs := make([]error, len(toPatch))
+	bar := progressbar.Default(int64(len(toPatch)))
+
+	patchProfile := func(ctx context.Context, i int) ([]byte, error) {
+		update := updates[toPatch[i]]
+
+		var patch profilePatch
+		patch.Profile.Status = update.status
+		patch.Profile.Archived = update.archived
+		patch.Profile.Attributes = update.attributes
+
+		body, err := json.Marshal(patch)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = client.WithContext(ctx).Patch("profiles", update.result.ID, body)
+		if err != nil && ctx.Err() == nil {
+			patchErrs[i] = err // recorded against the profile instead of stopping the command
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	handlePatch := func(i int, resp []byte) (bool, error) {
+		bar.Add(1)
+
+		update := &updates[toPatch[i]]
+		if patchErrs[i] != nil {
+			update.result.Result, update.result.Error = "failed", utilities.RowError(patchErrs[i])
+		} else {
+			update.result.Result = done
+		}
+
+		return true, output.Write(update.result)
+	}
+
+	return utilities.FetchPages(ctx, concurrency, 0, len(toPatch), 1, patchProfile, handlePatch)
+}
+
+func countResults(updates []profileUpdate, result string) int {
+	count := 0
+	for _, update := range updates {
+		if update.result.Result == result {
+			count++
+		}
+	}
+	return count
+}
+
+// writeInputRows writes rows back out as a csv file with the columns of the input file
+func writeInputRows(fileLoc string, columns []string, rows []utilities.InputRow) error {
+	file, err := os.Create(fileLoc)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = row.Values[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
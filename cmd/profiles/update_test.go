@@ -0,0 +1,183 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package profiles
+
+import (
+	"maps"
+	"nerm/cmd/utilities"
+	"testing"
+)
+
+var testAttributes = []utilities.Attribute{
+	{ID: "a1", UID: "email", Label: "Email"},
+	{ID: "a2", UID: "title", Label: "Title"},
+	{ID: "a3", UID: "old_code", Label: "Old Code", Archived: true},
+	{ID: "a4", UID: "manager", Label: "Manager"},
+	{ID: "a5", UID: "backup_manager", Label: "Manager"},
+}
+
+func testProfile() ProfileJsonFileData {
+	return ProfileJsonFileData{
+		ID:         "p1",
+		UID:        "jdoe",
+		Name:       "Jane Doe",
+		Status:     "Active",
+		Attributes: map[string]string{"email": "jane@example.com", "title": "Engineer"},
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]string
+		clearEmpty bool
+		result     string
+		changes    map[string]string
+		attributes map[string]string
+		status     string
+	}{
+		{
+			name:    "changed attribute by label",
+			values:  map[string]string{"id": "p1", "Title": "Manager"},
+			result:  "would_update",
+			changes: map[string]string{"title": "Engineer -> Manager"}, attributes: map[string]string{"title": "Manager"},
+		},
+		{
+			name:    "changed attribute by UID",
+			values:  map[string]string{"id": "p1", "email": "jane@new.example.com"},
+			result:  "would_update",
+			changes: map[string]string{"email": "jane@example.com -> jane@new.example.com"}, attributes: map[string]string{"email": "jane@new.example.com"},
+		},
+		{
+			name:    "same values",
+			values:  map[string]string{"id": "p1", "email": "jane@example.com", "Title": "Engineer"},
+			result:  "unchanged",
+			changes: map[string]string{}, attributes: map[string]string{},
+		},
+		{
+			name:    "empty value is skipped",
+			values:  map[string]string{"id": "p1", "Title": ""},
+			result:  "unchanged",
+			changes: map[string]string{}, attributes: map[string]string{},
+		},
+		{
+			name:       "empty value is cleared with clear_empty",
+			values:     map[string]string{"id": "p1", "Title": ""},
+			clearEmpty: true,
+			result:     "would_update",
+			changes:    map[string]string{"title": "Engineer -> "}, attributes: map[string]string{"title": ""},
+		},
+		{
+			name:   "status",
+			values: map[string]string{"id": "p1", "Status": "on leave"},
+			result: "would_update", status: "On Leave",
+			changes: map[string]string{"status": "Active -> On Leave"}, attributes: map[string]string{},
+		},
+		{
+			name:   "invalid status",
+			values: map[string]string{"id": "p1", "Status": "Gone"},
+			result: "invalid",
+		},
+		{
+			name:   "archived attribute",
+			values: map[string]string{"id": "p1", "Old Code": "X1"},
+			result: "invalid",
+		},
+		{
+			name:   "ambiguous label",
+			values: map[string]string{"id": "p1", "Manager": "p9"},
+			result: "invalid",
+		},
+		{
+			name:    "ambiguous label given by UID",
+			values:  map[string]string{"id": "p1", "backup_manager": "p9"},
+			result:  "would_update",
+			changes: map[string]string{"backup_manager": " -> p9"}, attributes: map[string]string{"backup_manager": "p9"},
+		},
+		{
+			name:   "unknown column",
+			values: map[string]string{"id": "p1", "Favourite Colour": "Blue"},
+			result: "invalid",
+		},
+		{
+			name:    "profile fields and report columns are skipped",
+			values:  map[string]string{"id": "p1", "uid": "jdoe", "name": "Someone Else", "result": "failed", "error": "boom"},
+			result:  "unchanged",
+			changes: map[string]string{}, attributes: map[string]string{},
+		},
+	}
+
+	lookup := newAttributeLookup(testAttributes)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var columns []string
+			for column := range test.values {
+				columns = append(columns, column)
+			}
+
+			var update profileUpdate
+			planUpdate(&update, testProfile(), utilities.InputRow{Values: test.values}, columns, []string{"id"}, lookup, test.clearEmpty)
+
+			if update.result.Result != test.result {
+				t.Fatalf("result is %q (%s), want %q", update.result.Result, update.result.Error, test.result)
+			}
+			if test.result == "invalid" {
+				if update.result.Error == "" {
+					t.Error("invalid row has no error")
+				}
+				if update.result.Changes != nil {
+					t.Errorf("invalid row has changes %v", update.result.Changes)
+				}
+				return
+			}
+			if !maps.Equal(update.result.Changes, test.changes) {
+				t.Errorf("changes are %v, want %v", update.result.Changes, test.changes)
+			}
+			if !maps.Equal(update.attributes, test.attributes) {
+				t.Errorf("attributes are %v, want %v", update.attributes, test.attributes)
+			}
+			if update.status != test.status {
+				t.Errorf("status is %q, want %q", update.status, test.status)
+			}
+		})
+	}
+}
+
+func TestFindProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		resp  string
+		id    string
+		uid   string
+		found bool
+	}{
+		{name: "single profile", resp: `{"profile":{"id":"p1","uid":"jdoe"}}`, id: "p1", found: true},
+		{name: "list of profiles", resp: `{"profiles":[{"id":"p1","uid":"jdoe"}]}`, id: "p1", found: true},
+		{name: "other profile", resp: `{"profile":{"id":"p2","uid":"jsmith"}}`, id: "p1", found: false},
+		{name: "by UID", resp: `{"profiles":[{"id":"p2","uid":"jsmith"},{"id":"p1","uid":"jdoe"}]}`, uid: "jdoe", found: true},
+		{name: "by UID with other profiles only", resp: `{"profiles":[{"id":"p2","uid":"jsmith"}]}`, uid: "jdoe", found: false},
+		{name: "empty response", resp: ``, id: "p1", found: false},
+		{name: "no profiles", resp: `{"profiles":[]}`, uid: "", found: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, found, err := findProfile([]byte(test.resp), test.id, test.uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.found {
+				t.Fatalf("found is %v, want %v", found, test.found)
+			}
+			if found && profile.ID != "p1" {
+				t.Errorf("found profile %q, want p1", profile.ID)
+			}
+		})
+	}
+
+	if _, _, err := findProfile([]byte(`{"profile":`), "p1", ""); err == nil {
+		t.Error("a broken response did not return an error")
+	}
+}
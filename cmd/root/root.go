@@ -5,10 +5,15 @@ package root
 
 import (
 	"nerm/cmd/advanced_search"
+	"nerm/cmd/attributes"
+	"nerm/cmd/cache"
+	"nerm/cmd/configs"
 	"nerm/cmd/environment"
 	"nerm/cmd/health_check"
 	"nerm/cmd/identity_proofing"
+	"nerm/cmd/profile_types"
 	"nerm/cmd/profiles"
+	"nerm/cmd/utilities"
 	"nerm/cmd/workflow_sessions"
 	"os"
 
@@ -36,22 +41,58 @@ func Execute() {
 
 func NewRootCommand() *cobra.Command {
 	root := &cobra.Command{
-		Use:          "nerm",
-		Long:         "starts the use of the CLi",
-		Example:      "nerm",
-		SilenceUsage: true,
+		Use:           "nerm",
+		Long:          "starts the use of the CLi",
+		Example:       "nerm",
+		SilenceUsage:  true,
+		SilenceErrors: true, // errors are printed by main with a summary and exit code
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("max-retries") {
+				maxRetries, _ := cmd.Flags().GetInt("max-retries")
+				if maxRetries < 0 {
+					return utilities.ValidationErrorf("--max-retries can not be negative")
+				}
+				configs.SetOverride("MAX_RETRIES", maxRetries)
+			}
+
+			if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+				configs.SetOverride("NO_CACHE", true)
+			}
+
+			// the default comes from the config file, so it is checked even when --format is not used
+			values, _ := cmd.Flags().GetStringSlice("format")
+			formats, err := utilities.ParseFormats(values)
+			if err != nil {
+				return err
+			}
+			configs.SetOverride("FORMAT", formats)
+
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
 	}
 
+	root.PersistentFlags().Int("max-retries", configs.GetMaxRetries(), "How many times to retry a request that was rate limited or hit a temporary server error")
+	root.PersistentFlags().StringSlice("format", configs.GetOutputFormats(), "Formats to write exports in: json, jsonl, csv, tsv, xlsx, parquet. Use more than one with commas (ie json,xlsx)")
+
+	root.PersistentFlags().Bool("no-cache", false, "Pull profile types, attributes, workflows and advanced searches from the tenant instead of the metadata cache")
+
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return utilities.ValidationErrorf("%w\nRun '%s --help' for usage", err, cmd.CommandPath())
+	})
+
 	root.AddCommand(
 		environment.NewEnvironmentCommand(),
 		health_check.NewHealthCheckCommand(),
 		profiles.NewProfilesCommand(),
+		profile_types.NewProfileTypesCommand(),
 		workflow_sessions.NewWorkflowSessionsCommand(),
 		identity_proofing.NewIdentityProofingCommand(),
 		advanced_search.NewAdvancedSearchCommand(),
+		attributes.NewAttributesCommand(),
+		cache.NewCacheCommand(),
 	)
 
 	return root
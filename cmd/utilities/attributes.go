@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"strings"
+)
+
+// Attribute is an ne_attribute of a profile type
+type Attribute struct {
+	ID                      string `json:"id"`
+	UID                     string `json:"uid"`
+	Label                   string `json:"label"`
+	ToolTip                 string `json:"tool_tip"`
+	DataType                string `json:"data_type"`
+	ProfileTypeID           string `json:"profile_type_id"`
+	DateFormat              string `json:"date_format"`
+	Description             string `json:"description"`
+	Archived                bool   `json:"archived"`
+	Type                    string `json:"type"`
+	OwnershipDriven         bool   `json:"ownership_driven"`
+	AllowMultipleSelections bool   `json:"allow_multiple_selections"`
+	SelectableStatus        string `json:"selectable_status"`
+	FilteredByNeAttribute   bool   `json:"filtered_by_ne_attribute"`
+	FilteringNeAttributeID  string `json:"filtering_ne_attribute_id"`
+	NeAttributeFilterID     string `json:"ne_attribute_filter_id"`
+	RiskType                string `json:"risk_type"`
+	Crypt                   bool   `json:"crypt"`
+	RiskScoreSetting        string `json:"risk_score_setting"`
+	CreatedAt               string `json:"created_at"`
+	UpdatedAt               string `json:"updated_at"`
+	ArchivedOn              string `json:"archived_on"`
+	LegacyID                int    `json:"legacy_id"`
+}
+
+// ListAttributes returns the ne_attributes of a tenant, or of one profile type when profileTypeID is set.
+// The full list is read from the metadata cache, or pulled once per run
+func ListAttributes(ctx context.Context, client *Client, profileTypeID string) ([]Attribute, error) {
+	all, err := cachedList[Attribute](ctx, client, Attributes)
+	if err != nil || profileTypeID == "" {
+		return all, err
+	}
+
+	var attributes []Attribute
+	for _, attribute := range all {
+		if attribute.ProfileTypeID == profileTypeID {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes, nil
+}
+
+// FindAttribute finds an attribute by its ID, UID or label (ignoring case), so commands can take any of them.
+// A label used by more than one attribute is an error that lists each of them
+func FindAttribute(ctx context.Context, client *Client, value string, profileTypeID string) (Attribute, error) {
+	value = strings.TrimSpace(value)
+
+	attributes, err := ListAttributes(ctx, client, profileTypeID)
+	if err != nil {
+		return Attribute{}, err
+	}
+
+	matches := matchAttributes(attributes, value)
+	if len(matches) == 0 && refreshCachedList(ctx, client, Attributes) {
+		// the attribute may have been created after the cache was filled
+		if attributes, err = ListAttributes(ctx, client, profileTypeID); err != nil {
+			return Attribute{}, err
+		}
+		matches = matchAttributes(attributes, value)
+	}
+
+	switch len(matches) {
+	case 0:
+		return Attribute{}, ValidationErrorf("no attribute has the ID, UID or label %q", value)
+	case 1:
+		return matches[0], nil
+	}
+
+	candidates := make([]string, len(matches))
+	for i, attribute := range matches {
+		candidates[i] = attribute.Label + " (uid: " + attribute.UID + ", id: " + attribute.ID + ")"
+	}
+	return Attribute{}, ValidationErrorf("more than one attribute matches %q. Use the ID of one of them:\n  %s", value, strings.Join(candidates, "\n  "))
+}
+
+// matchAttributes finds the attributes with the ID, UID or label of value. A matching ID or UID wins over labels
+func matchAttributes(attributes []Attribute, value string) []Attribute {
+	var byUID, byLabel []Attribute
+	for _, attribute := range attributes {
+		switch {
+		case attribute.ID == value:
+			return []Attribute{attribute}
+		case attribute.UID == value:
+			byUID = append(byUID, attribute)
+		case strings.EqualFold(attribute.Label, value):
+			byLabel = append(byLabel, attribute)
+		}
+	}
+
+	if len(byUID) > 0 {
+		return byUID
+	}
+	return byLabel
+}
@@ -0,0 +1,316 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nerm/cmd/configs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// CachedKinds are the lists kept in the metadata cache
+var CachedKinds = []ObjectKind{ProfileTypes, Attributes, Workflows, AdvancedSearches, RiskLevels}
+
+// cacheFile is one list of the metadata cache, ie ~/.nerm/cache/<env>/profile_types.json
+type cacheFile struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Records   []json.RawMessage `json:"records"`
+}
+
+// CacheEntry describes one list of the metadata cache
+type CacheEntry struct {
+	Kind      ObjectKind
+	Records   int
+	FetchedAt time.Time
+}
+
+// lookups keeps the lists pulled for lookups, by tenant and endpoint, so each list is only read once per run.
+// The mutex only guards the maps. Each list has its own lock, held while it is read or pulled, so lookups of
+// other lists are not held up by a slow pull and a list is never pulled twice at the same time
+var lookups = struct {
+	sync.Mutex
+	lists    map[string][]json.RawMessage
+	fromDisk map[string]bool // lists read from the metadata cache rather than pulled in this run
+	locks    map[string]*sync.Mutex
+}{lists: map[string][]json.RawMessage{}, fromDisk: map[string]bool{}, locks: map[string]*sync.Mutex{}}
+
+// lockList locks one list of lookups, and returns the function that unlocks it
+func lockList(key string) func() {
+	lookups.Lock()
+	lock, ok := lookups.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		lookups.locks[key] = lock
+	}
+	lookups.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// storeList keeps a list in lookups. fromDisk is set for lists read from the metadata cache
+func storeList(key string, records []json.RawMessage, fromDisk bool) {
+	lookups.Lock()
+	defer lookups.Unlock()
+
+	lookups.lists[key] = records
+	if fromDisk {
+		lookups.fromDisk[key] = true
+	} else {
+		delete(lookups.fromDisk, key)
+	}
+}
+
+func cachePath(environment string, kind ObjectKind) string {
+	return filepath.Join(configs.GetCacheFolder(), environment, kind.Endpoint+".json")
+}
+
+// cachedList returns the records of a list endpoint. They come from memory, then from the metadata cache of the
+// client's environment while it is newer than the cache TTL, and are pulled from the API otherwise. When the API
+// can not be reached, an older cache is used instead
+func cachedList[T any](ctx context.Context, client *Client, kind ObjectKind) ([]T, error) {
+	key := client.Host + "|" + kind.Endpoint
+
+	defer lockList(key)()
+
+	lookups.Lock()
+	records, ok := lookups.lists[key]
+	lookups.Unlock()
+
+	if !ok {
+		fromDisk := false
+		useDisk := configs.GetCacheEnabled() && client.Environment != ""
+
+		var cached *cacheFile
+		if useDisk {
+			cached, _ = readCacheFile(client.Environment, kind) // a missing or broken cache is pulled again
+		}
+
+		if cached != nil && time.Since(cached.FetchedAt) < configs.GetCacheTTL() {
+			records, fromDisk = cached.Records, true
+		} else {
+			pulled, err := NewPaginator[json.RawMessage](client, kind.Endpoint, kind.Key, nil).All(ctx)
+			switch {
+			case err != nil && cached != nil && ExitCode(err) == ExitNetwork:
+				fmt.Fprintln(os.Stderr, "Could not reach the tenant. Using the "+kind.Name+" list cached on "+cached.FetchedAt.Local().Format(time.DateTime))
+				records, fromDisk = cached.Records, true
+			case err != nil:
+				return nil, err
+			default:
+				records = pulled
+				if useDisk {
+					if err := writeCacheFile(client.Environment, kind, records); err != nil {
+						fmt.Fprintln(os.Stderr, "Could not update the metadata cache:", err)
+					}
+				}
+			}
+		}
+
+		storeList(key, records, fromDisk)
+	}
+
+	list := make([]T, 0, len(records))
+	for _, record := range records {
+		var item T
+		if err := json.Unmarshal(record, &item); err != nil {
+			return nil, fmt.Errorf("can not unmarshal %s: %w", kind.Endpoint, err)
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func readCacheFile(environment string, kind ObjectKind) (*cacheFile, error) {
+	data, err := os.ReadFile(cachePath(environment, kind))
+	if err != nil {
+		return nil, err
+	}
+
+	var cached cacheFile
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+func writeCacheFile(environment string, kind ObjectKind, records []json.RawMessage) error {
+	path := cachePath(environment, kind)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cacheFile{FetchedAt: time.Now().UTC(), Records: records})
+	if err != nil {
+		return err
+	}
+
+	// written next to the cache and then renamed, so a run that is stopped never leaves half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// refreshCachedList pulls a list again when it was read from the metadata cache in this run, so objects created
+// since the cache was filled can be found. It reports whether the list was pulled
+func refreshCachedList(ctx context.Context, client *Client, kind ObjectKind) bool {
+	key := client.Host + "|" + kind.Endpoint
+
+	defer lockList(key)()
+
+	lookups.Lock()
+	fromDisk := lookups.fromDisk[key]
+	lookups.Unlock()
+
+	if !fromDisk {
+		return false
+	}
+
+	records, err := NewPaginator[json.RawMessage](client, kind.Endpoint, kind.Key, nil).All(ctx)
+	if err != nil {
+		return false // the cached list is kept
+	}
+	if client.Environment != "" {
+		if err := writeCacheFile(client.Environment, kind, records); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not update the metadata cache:", err)
+		}
+	}
+
+	storeList(key, records, false)
+	return true
+}
+
+// RefreshCache pulls every cached list of the client's environment again, whatever their age
+func RefreshCache(ctx context.Context, client *Client) ([]CacheEntry, error) {
+	if client.Environment == "" {
+		return nil, ValidationErrorf("the metadata cache is only kept for configured environments")
+	}
+
+	var entries []CacheEntry
+	for _, kind := range CachedKinds {
+		entry, err := refreshList(ctx, client, kind)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// refreshList pulls one list for RefreshCache and writes it to the metadata cache
+func refreshList(ctx context.Context, client *Client, kind ObjectKind) (CacheEntry, error) {
+	key := client.Host + "|" + kind.Endpoint
+
+	defer lockList(key)()
+
+	records, err := NewPaginator[json.RawMessage](client, kind.Endpoint, kind.Key, nil).All(ctx)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	if err := writeCacheFile(client.Environment, kind, records); err != nil {
+		return CacheEntry{}, err
+	}
+
+	storeList(key, records, false)
+	return CacheEntry{Kind: kind, Records: len(records), FetchedAt: time.Now()}, nil
+}
+
+// InvalidateCache drops lists of the client's environment after a command changes them, so the next lookup pulls
+// them again
+func InvalidateCache(client *Client, kinds ...ObjectKind) {
+	for _, kind := range kinds {
+		invalidateList(client, kind)
+	}
+}
+
+func invalidateList(client *Client, kind ObjectKind) {
+	key := client.Host + "|" + kind.Endpoint
+
+	// waits for a pull of the list that is under way, so it is not stored after it was dropped
+	defer lockList(key)()
+
+	lookups.Lock()
+	delete(lookups.lists, key)
+	delete(lookups.fromDisk, key)
+	lookups.Unlock()
+
+	if client.Environment == "" {
+		return
+	}
+	if err := os.Remove(cachePath(client.Environment, kind)); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "Could not update the metadata cache:", err)
+	}
+}
+
+// CacheEntries lists what is cached for an environment. Lists that were never cached are left out
+func CacheEntries(environment string) []CacheEntry {
+	var entries []CacheEntry
+	for _, kind := range CachedKinds {
+		cached, err := readCacheFile(environment, kind)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{Kind: kind, Records: len(cached.Records), FetchedAt: cached.FetchedAt})
+	}
+	return entries
+}
+
+// ClearCache removes the metadata cache of an environment, or of every environment when it is empty
+func ClearCache(environment string) error {
+	folder := configs.GetCacheFolder()
+	if folder == "" {
+		return nil
+	}
+	if environment != "" {
+		folder = filepath.Join(folder, environment)
+	}
+	return os.RemoveAll(folder)
+}
+
+// CompleteNames completes a flag with the names of every object of a kind. The metadata cache is used whatever its
+// age, so completion stays fast and works offline. The tenant is only asked when nothing is cached yet
+func CompleteNames(kind ObjectKind) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		client, err := ClientFromContext(cmd.Context())
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var objects []NamedObject
+		if cached, err := readCacheFile(client.Environment, kind); err == nil && configs.GetCacheEnabled() {
+			for _, record := range cached.Records {
+				var object NamedObject
+				if json.Unmarshal(record, &object) == nil {
+					objects = append(objects, object)
+				}
+			}
+		} else if objects, err = ListObjects(cmd.Context(), client, kind); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		// comma separated flags complete the last value
+		prefix := ""
+		if index := strings.LastIndex(toComplete, ","); index >= 0 {
+			prefix, toComplete = toComplete[:index+1], toComplete[index+1:]
+		}
+
+		var names []string
+		for _, object := range objects {
+			name := object.DisplayName()
+			if name != "" && strings.HasPrefix(strings.ToLower(name), strings.ToLower(toComplete)) {
+				names = append(names, prefix+name+"\t"+object.ID)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedListLocksEachList(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	var typePulls atomic.Int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		objects := []NamedObject{}
+		first := r.URL.Query().Get("offset") == "0"
+
+		key := "workflows"
+		if strings.HasSuffix(r.URL.Path, "/profile_types") {
+			key = "profile_types"
+			if first {
+				typePulls.Add(1)
+				started <- struct{}{}
+				<-release // the profile types are slow to answer
+				objects = append(objects, NamedObject{ID: "t1", Name: "Employee"})
+			}
+		} else if first {
+			objects = append(objects, NamedObject{ID: "w1", Name: "Onboard"})
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{key: objects})
+	})
+	client.Environment = "locks"
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			types, err := ListObjects(context.Background(), client, ProfileTypes)
+			if err == nil && len(types) != 1 {
+				t.Errorf("got %d profile types, want 1", len(types))
+			}
+			errs <- err
+		}()
+	}
+	<-started
+
+	// another list is not held up by the profile types being pulled
+	done := make(chan error, 1)
+	go func() {
+		_, err := ListObjects(context.Background(), client, Workflows)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the workflows waited for the profile types")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// lookups of the same list wait for the pull under way instead of pulling it again
+	if got := typePulls.Load(); got != 1 {
+		t.Errorf("pulled the profile types %d times, want 1", got)
+	}
+}
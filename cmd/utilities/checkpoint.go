@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint records how far an export got, so a failed or interrupted run can be resumed
+// with --resume instead of starting again from the first page
+type Checkpoint struct {
+	Command     string            `json:"command"`
+	Environment string            `json:"environment"`
+	Params      string            `json:"params"`             // encoded query parameters of the export, without offset / after_id
+	Offset      int               `json:"offset"`             // offset of the next page to get
+	AfterID     string            `json:"after_id,omitempty"` // after_id of the next page to get, when using after_id pagination
+	GetLimit    int               `json:"get_limit"`          // total number of records the export will pull
+	OutputLoc   string            `json:"output"`             // export file path without the extension
+	Formats     []string          `json:"formats"`            // formats the export is written in
+	Files       map[string]int64  `json:"files"`              // size of each file being written after the last complete page
+	Flags       map[string]string `json:"flags,omitempty"`    // command specific settings needed to carry on
+	UpdatedAt   string            `json:"updated_at"`
+}
+
+// NewCheckpoint starts a checkpoint for an export stored at outputLoc
+func NewCheckpoint(command string, environment string, outputLoc string) *Checkpoint {
+	return &Checkpoint{
+		Command:     command,
+		Environment: environment,
+		OutputLoc:   outputLoc,
+		Flags:       map[string]string{},
+	}
+}
+
+// LoadCheckpoint reads a checkpoint file and makes sure it belongs to the command resuming it
+func LoadCheckpoint(fileLoc string, command string) (*Checkpoint, error) {
+	data, err := os.ReadFile(fileLoc)
+	if err != nil {
+		return nil, ValidationErrorf("reading checkpoint %s: %w", fileLoc, err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, ValidationErrorf("reading checkpoint %s: %w", fileLoc, err)
+	}
+
+	if checkpoint.Command != command {
+		return nil, ValidationErrorf("checkpoint %s was made by 'nerm %s', not 'nerm %s'", fileLoc, checkpoint.Command, command)
+	}
+	if checkpoint.Flags == nil {
+		checkpoint.Flags = map[string]string{}
+	}
+
+	return &checkpoint, nil
+}
+
+// Path is where the checkpoint is stored, next to the export files
+func (c *Checkpoint) Path() string {
+	return c.OutputLoc + ".checkpoint.json"
+}
+
+// Save writes the checkpoint to a temporary file first, so an interrupted save never leaves a broken checkpoint
+func (c *Checkpoint) Save() error {
+	c.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(c.Path()+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(c.Path()+".tmp", c.Path())
+}
+
+// ExportStreams is anything writing the files of an export, ie an Exporter
+type ExportStreams interface {
+	Streams() []*JSONWriter
+}
+
+// Advance stores the position of the next page, once everything before it is written by output
+func (c *Checkpoint) Advance(offset int, afterID string, output ExportStreams) error {
+	files := map[string]int64{}
+
+	for _, stream := range output.Streams() {
+		if err := stream.Flush(); err != nil {
+			return err
+		}
+
+		info, err := stream.file.Stat()
+		if err != nil {
+			return err
+		}
+		files[stream.Path()] = info.Size()
+	}
+
+	c.Offset = offset
+	c.AfterID = afterID
+	c.Files = files
+
+	return c.Save()
+}
+
+// Remove deletes the checkpoint once the export has finished
+func (c *Checkpoint) Remove() error {
+	err := os.Remove(c.Path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Failed adds a hint on how to resume the export to an error that stopped it
+func (c *Checkpoint) Failed(err error) error {
+	return fmt.Errorf("%w\nThe export stopped at record %d. Run the command again with --resume %s to carry on", err, c.Offset, c.Path())
+}
@@ -0,0 +1,325 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"nerm/cmd/configs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource returns the value used in the Authorization header of each request (ie "Bearer abc123")
+type TokenSource func() (string, error)
+
+// StaticToken always returns the given token. Useful when the token does not come from the keyring
+func StaticToken(token string) TokenSource {
+	return func() (string, error) {
+		return token, nil
+	}
+}
+
+// KeyringToken reads the token of a tenant from the keyring the first time it is needed and reuses it after that
+func KeyringToken(tenant string, baseurl string) TokenSource {
+	var once sync.Once
+	var token string
+	var err error
+
+	return func() (string, error) {
+		once.Do(func() {
+			token, err = configs.GetAPITokenFor(tenant, baseurl)
+		})
+		return token, err
+	}
+}
+
+// Client makes requests against the API of a single NERM tenant
+type Client struct {
+	Environment string // name of the environment in the config file. Empty for clients that are not built from it
+	Tenant      string
+	BaseURL     string
+	Host        string // scheme and host of the tenant, ie https://tenant.nonemployee.com. Can be pointed at a test server
+
+	Token      TokenSource
+	HTTPClient *http.Client
+	Limiter    *RateLimiter // nil means requests are not rate limited
+
+	MaxRetries   int           // how many times a failed idempotent request is retried
+	RetryWaitMin time.Duration // wait before the first retry. Doubles with each retry
+	RetryWaitMax time.Duration // longest wait between retries, unless the API sends a longer Retry-After
+
+	ctx context.Context
+}
+
+// APIError is returned when the API answers with a non 2xx status code
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	message := e.Message()
+	if message == "" {
+		return fmt.Sprintf("%s %s returned %s", e.Method, e.URL, e.Status)
+	}
+	return fmt.Sprintf("%s %s returned %s: %s", e.Method, e.URL, e.Status, message)
+}
+
+// Message pulls the error message out of the body the API returned. Falls back to the raw body
+func (e *APIError) Message() string {
+	var body struct {
+		Error   interface{} `json:"error"`
+		Errors  interface{} `json:"errors"`
+		Message string      `json:"message"`
+	}
+
+	if err := json.Unmarshal(e.Body, &body); err == nil {
+		switch {
+		case body.Message != "":
+			return body.Message
+		case body.Error != nil:
+			return fmt.Sprint(body.Error)
+		case body.Errors != nil:
+			return fmt.Sprint(body.Errors)
+		}
+	}
+
+	message := strings.TrimSpace(string(e.Body))
+	if len(message) > 500 {
+		message = message[:500] + "..."
+	}
+	return message
+}
+
+// RowError is the reason a row failed, as written to a results file. API errors only keep the status and message
+func RowError(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if message := apiErr.Message(); message != "" {
+			return apiErr.Status + ": " + message
+		}
+		return apiErr.Status
+	}
+	return err.Error()
+}
+
+// NewClient creates a Client for a tenant. The host defaults to https://{tenant}.{baseurl}
+func NewClient(tenant string, baseurl string, token TokenSource) *Client {
+	return &Client{
+		Tenant:     tenant,
+		BaseURL:    baseurl,
+		Host:       "https://" + tenant + "." + baseurl,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+
+		MaxRetries:   configs.GetMaxRetries(),
+		RetryWaitMin: 500 * time.Millisecond,
+		RetryWaitMax: 30 * time.Second,
+
+		ctx: context.Background(),
+	}
+}
+
+// NewClientForEnvironment creates a Client for one of the environments in the config file.
+// The token is read from the keyring
+func NewClientForEnvironment(environmentName string) (*Client, error) {
+	tenant := configs.GetTenantFor(environmentName)
+	if tenant == "" {
+		return nil, ValidationErrorf("environment %q is not configured. Use 'nerm env create' or 'nerm env use' first", environmentName)
+	}
+	baseurl := configs.GetBaseURLFor(environmentName)
+
+	client := NewClient(tenant, baseurl, KeyringToken(tenant, baseurl))
+	client.Environment = strings.ToLower(environmentName)
+	requestsPerSecond, burst := configs.GetRateLimitFor(environmentName)
+	client.Limiter = SharedRateLimiter(client.Host, requestsPerSecond, burst)
+
+	return client, nil
+}
+
+type clientContextKey struct{}
+
+// ContextWithClient stores a Client in a context so commands use it instead of building one from the config file
+func ContextWithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the Client stored in the context, or a new one for the current environment
+func ClientFromContext(ctx context.Context) (*Client, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if client, ok := ctx.Value(clientContextKey{}).(*Client); ok && client != nil {
+		return client.WithContext(ctx), nil
+	}
+
+	client, err := NewClientForEnvironment(configs.GetCurrentEnvironment())
+	if err != nil {
+		return nil, err
+	}
+	return client.WithContext(ctx), nil
+}
+
+// WithContext returns a copy of the Client that makes its requests with ctx
+func (c *Client) WithContext(ctx context.Context) *Client {
+	copied := *c
+	copied.ctx = ctx
+	return &copied
+}
+
+// Context returns the context requests are made with
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// URL builds the full URL for an API endpoint, ie {host}/api/profiles/{id}?{params}
+func (c *Client) URL(endpoint string, id string, params string) string {
+	url := c.Host + "/api/" + endpoint
+	if id != "" {
+		url = url + "/" + id
+	}
+	if params == "" {
+		return url
+	}
+	return url + "?" + params
+}
+
+func (c *Client) Get(endpoint string, id string, params string) ([]byte, error) {
+	return c.Do(http.MethodGet, c.URL(endpoint, id, params), nil)
+}
+
+func (c *Client) Post(endpoint string, params string, jsonStr []byte) ([]byte, error) {
+	return c.Do(http.MethodPost, c.URL(endpoint, "", params), jsonStr)
+}
+
+func (c *Client) Patch(endpoint string, id string, jsonStr []byte) ([]byte, error) {
+	return c.Do(http.MethodPatch, c.URL(endpoint, id, ""), jsonStr)
+}
+
+func (c *Client) Delete(endpoint string, id string, params string) ([]byte, error) {
+	return c.Do(http.MethodDelete, c.URL(endpoint, id, params), nil)
+}
+
+// RunAdvancedSearch runs a saved Advanced Search and returns one page of the matching profiles
+func (c *Client) RunAdvancedSearch(id string, params string) ([]byte, error) {
+	return c.Get("advanced_search/"+id+"/run", "", params)
+}
+
+// HealthCheck pings the health_check page of the tenant. It does not need a token
+func (c *Client) HealthCheck() ([]byte, error) {
+	return c.do(http.MethodGet, c.Host+"/health_check", nil, "")
+}
+
+// Do sends a request with the tenant's token and returns the body of the response.
+// Any non 2xx response is returned as an *APIError. Idempotent requests are retried
+// when the API is rate limiting or temporarily unavailable
+func (c *Client) Do(method string, url string, jsonStr []byte) ([]byte, error) {
+	var token string
+	if c.Token != nil {
+		var err error
+		token, err = c.Token()
+		if err != nil {
+			return nil, AuthErrorf("reading the token for %s: %w", c.Tenant, err)
+		}
+	}
+
+	return c.do(method, url, jsonStr, token)
+}
+
+func (c *Client) do(method string, url string, jsonStr []byte, token string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if jsonStr != nil {
+			body = bytes.NewReader(jsonStr)
+		}
+
+		req, err := http.NewRequestWithContext(c.Context(), method, url, body)
+		if err != nil {
+			return nil, err
+		}
+
+		if token != "" {
+			req.Header.Add("Authorization", token)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Accept", "application/json")
+
+		if c.Limiter != nil {
+			if err := c.Limiter.Wait(c.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		respBody, err := c.send(req)
+		if err == nil || attempt >= c.MaxRetries || !shouldRetry(method, err) {
+			return respBody, err
+		}
+
+		wait := c.retryWait(attempt, err)
+		fmt.Fprintf(os.Stderr, "\n%s. Retrying in %s (%d of %d)\n", retryReason(err), wait.Round(time.Millisecond), attempt+1, c.MaxRetries)
+
+		select {
+		case <-c.Context().Done():
+			return nil, c.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(req *http.Request) ([]byte, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if c.Limiter != nil {
+		c.Limiter.Observe(resp.Header)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, &APIError{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+			Body:       respBody,
+		}
+	}
+
+	return respBody, nil
+}
+
+// IsNotFound reports whether err is an API error with a 404 status
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
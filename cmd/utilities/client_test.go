@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Client pointed at a test server that answers with handler. Retries wait 1ms
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient("tenant", "example.com", StaticToken("Bearer token"))
+	client.Host = server.URL
+	client.MaxRetries = 3
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+	return client
+}
+
+func TestClientURL(t *testing.T) {
+	client := NewClient("tenant", "example.com", nil)
+
+	tests := []struct {
+		endpoint, id, params string
+		want                 string
+	}{
+		{"profiles", "", "", "https://tenant.example.com/api/profiles"},
+		{"profiles", "abc", "", "https://tenant.example.com/api/profiles/abc"},
+		{"profiles", "", "limit=10&offset=20", "https://tenant.example.com/api/profiles?limit=10&offset=20"},
+		{"advanced_search/abc/run", "", "limit=5", "https://tenant.example.com/api/advanced_search/abc/run?limit=5"},
+	}
+	for _, test := range tests {
+		if got := client.URL(test.endpoint, test.id, test.params); got != test.want {
+			t.Errorf("URL(%q, %q, %q) = %q, want %q", test.endpoint, test.id, test.params, got, test.want)
+		}
+	}
+}
+
+func TestClientSendsRequest(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/profiles/abc" {
+			t.Errorf("got %s %s, want PATCH /api/profiles/abc", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"profile":{}}` {
+			t.Errorf("body = %s", body)
+		}
+		w.Write([]byte(`{"profile":{"id":"abc"}}`))
+	})
+
+	resp, err := client.Patch("profiles", "abc", []byte(`{"profile":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != `{"profile":{"id":"abc"}}` {
+		t.Errorf("response = %s", resp)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		message  string
+		exitCode int
+	}{
+		{"message", http.StatusUnprocessableEntity, `{"message":"name is required"}`, "name is required", ExitValidation},
+		{"error", http.StatusNotFound, `{"error":"Profile not found"}`, "Profile not found", ExitNotFound},
+		{"errors", http.StatusBadRequest, `{"errors":["bad status"]}`, "[bad status]", ExitValidation},
+		{"raw body", http.StatusUnauthorized, "token expired", "token expired", ExitAuth},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+
+			_, err := client.Get("profiles", "abc", "")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != test.status {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, test.status)
+			}
+			if apiErr.Method != http.MethodGet || apiErr.URL != client.URL("profiles", "abc", "") {
+				t.Errorf("request = %s %s", apiErr.Method, apiErr.URL)
+			}
+			if string(apiErr.Body) != test.body {
+				t.Errorf("Body = %s, want %s", apiErr.Body, test.body)
+			}
+			if got := apiErr.Message(); got != test.message {
+				t.Errorf("Message() = %q, want %q", got, test.message)
+			}
+			if got := ExitCode(err); got != test.exitCode {
+				t.Errorf("ExitCode = %d, want %d", got, test.exitCode)
+			}
+			if got, want := RowError(err), apiErr.Status+": "+test.message; got != want {
+				t.Errorf("RowError = %q, want %q", got, want)
+			}
+			if got := IsNotFound(err); got != (test.status == http.StatusNotFound) {
+				t.Errorf("IsNotFound = %v", got)
+			}
+		})
+	}
+}
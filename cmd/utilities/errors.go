@@ -0,0 +1,137 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Exit codes returned by the CLI. Scripts can use these to tell apart why a command failed
+const (
+	ExitOK          = 0   // command finished without an error
+	ExitGeneral     = 1   // any error not covered below
+	ExitValidation  = 2   // bad flags, arguments or input files
+	ExitAuth        = 3   // missing token or the API returned 401 / 403
+	ExitNotFound    = 4   // the API returned 404
+	ExitRateLimited = 5   // the API returned 429
+	ExitServer      = 6   // the API returned a 5xx
+	ExitNetwork     = 7   // the tenant could not be reached, or the request timed out
+	ExitInterrupted = 130 // the command was stopped with Ctrl+C
+)
+
+// ExitError ties an error to one of the exit codes above
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrorf creates an error for bad user input, which exits with ExitValidation
+func ValidationErrorf(format string, a ...any) error {
+	return &ExitError{Code: ExitValidation, Err: fmt.Errorf(format, a...)}
+}
+
+// AuthErrorf creates an error for a missing or unusable token, which exits with ExitAuth
+func AuthErrorf(format string, a ...any) error {
+	return &ExitError{Code: ExitAuth, Err: fmt.Errorf(format, a...)}
+}
+
+// ExitCode picks the exit code for an error returned by a command
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return ExitAuth
+		case apiErr.StatusCode == http.StatusNotFound:
+			return ExitNotFound
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return ExitRateLimited
+		case apiErr.StatusCode >= 500:
+			return ExitServer
+		case apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity:
+			return ExitValidation
+		}
+		return ExitGeneral
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ExitNetwork
+	}
+
+	return ExitGeneral
+}
+
+func exitCodeDescription(code int) string {
+	switch code {
+	case ExitValidation:
+		return "invalid input"
+	case ExitAuth:
+		return "authentication failed"
+	case ExitNotFound:
+		return "not found"
+	case ExitRateLimited:
+		return "rate limited"
+	case ExitServer:
+		return "server error"
+	case ExitNetwork:
+		return "network error"
+	case ExitInterrupted:
+		return "interrupted"
+	}
+	return "error"
+}
+
+// PrintErrorSummary writes the final summary of a failed command
+func PrintErrorSummary(w io.Writer, err error) {
+	code := ExitCode(err)
+
+	fmt.Fprintln(w, "\nError:", err)
+	fmt.Fprintln(w, "  Category: ", exitCodeDescription(code))
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		fmt.Fprintln(w, "  Status:   ", apiErr.Status)
+		fmt.Fprintln(w, "  Request:  ", apiErr.Method, apiErr.URL)
+	}
+
+	switch code {
+	case ExitAuth:
+		fmt.Fprintln(w, "  Hint:      check the token with 'nerm env update'")
+	case ExitRateLimited:
+		fmt.Fprintln(w, "  Hint:      wait a moment and run the command again")
+	case ExitNetwork:
+		fmt.Fprintln(w, "  Hint:      check the tenant and base url with 'nerm env show'")
+	}
+
+	fmt.Fprintln(w, "  Exit code:", code)
+}
@@ -0,0 +1,508 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats an export can be written in
+const (
+	FormatJSON    = "json"
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatTSV     = "tsv"
+	FormatXLSX    = "xlsx"
+	FormatParquet = "parquet"
+)
+
+// OutputFormats lists every supported format, in the order files are written
+var OutputFormats = []string{FormatJSON, FormatJSONL, FormatCSV, FormatTSV, FormatXLSX, FormatParquet}
+
+const (
+	rowsExtension   = ".rows.jsonl" // rows waiting to be written to the table formats
+	xlsxMaxRows     = 1048576       // rows per sheet allowed by Excel, including the header
+	parquetParallel = 4
+)
+
+// ParseFormats checks a list of formats (ie from --format json,csv), removing duplicates
+func ParseFormats(values []string) ([]string, error) {
+	var formats []string
+
+	for _, value := range values {
+		for _, format := range strings.Split(value, ",") {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format == "" {
+				continue
+			}
+			if !slices.Contains(OutputFormats, format) {
+				return nil, ValidationErrorf("%q is not a supported format. Use one or more of: %s", format, strings.Join(OutputFormats, ", "))
+			}
+			if !slices.Contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+	}
+
+	if len(formats) == 0 {
+		return nil, ValidationErrorf("no format given. Use one or more of: %s", strings.Join(OutputFormats, ", "))
+	}
+
+	return formats, nil
+}
+
+// Table describes how the records of an export are flattened into rows for the csv, tsv, xlsx and parquet formats
+type Table[T any] struct {
+	Headers []string
+	// Row returns the values for Headers, in the same order. Attributes become extra columns, sorted a-z
+	Row func(record T) (values []string, attributes map[string]string)
+}
+
+// exportRow is a flattened record, kept until the export finishes and the full list of attribute columns is known
+type exportRow struct {
+	Values     []string          `json:"v"`
+	Attributes map[string]string `json:"a,omitempty"`
+}
+
+// Exporter writes the records of an export to every requested format as they arrive.
+// JSON and JSON Lines are streamed straight to their files. The table formats need every attribute column
+// in their header, so their rows are streamed to a side file and turned into the final files by Finish
+type Exporter[T any] struct {
+	OutputLoc string // path of the export files without the extension
+	Formats   []string
+
+	table Table[T]
+	json  *JSONWriter
+	jsonl *JSONWriter
+	rows  *JSONWriter
+}
+
+// NewExporter starts a new export at outputLoc in each of the formats
+func NewExporter[T any](outputLoc string, formats []string, table Table[T]) (*Exporter[T], error) {
+	return openExporter(outputLoc, formats, table, CreateJSONFile)
+}
+
+// ResumeExporter carries on the export a checkpoint was saved for, dropping anything written after the last complete page
+func ResumeExporter[T any](checkpoint *Checkpoint, table Table[T]) (*Exporter[T], error) {
+	for fileLoc, size := range checkpoint.Files {
+		if err := os.Truncate(fileLoc, size); err != nil {
+			return nil, err
+		}
+	}
+	return openExporter(checkpoint.OutputLoc, checkpoint.Formats, table, AppendJSONFile)
+}
+
+func openExporter[T any](outputLoc string, formats []string, table Table[T], open func(fileLoc string, lines bool) (*JSONWriter, error)) (*Exporter[T], error) {
+	e := &Exporter[T]{OutputLoc: outputLoc, Formats: formats, table: table}
+
+	var err error
+	if slices.Contains(formats, FormatJSON) {
+		if e.json, err = open(outputLoc+".json", false); err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(formats, FormatJSONL) {
+		if e.jsonl, err = open(outputLoc+".jsonl", true); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	if e.hasTableFormat() {
+		if e.rows, err = open(outputLoc+rowsExtension, true); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// Write adds one record to every format
+func (e *Exporter[T]) Write(record T) error {
+	if e.json != nil {
+		if err := e.json.Write(record); err != nil {
+			return err
+		}
+	}
+	if e.jsonl != nil {
+		if err := e.jsonl.Write(record); err != nil {
+			return err
+		}
+	}
+	if e.rows != nil {
+		values, attributes := e.table.Row(record)
+		if err := e.rows.Write(exportRow{Values: values, Attributes: attributes}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered records to disk
+func (e *Exporter[T]) Flush() error {
+	for _, stream := range e.Streams() {
+		if err := stream.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Streams are the files written while the export runs, which a Checkpoint keeps track of
+func (e *Exporter[T]) Streams() []*JSONWriter {
+	var streams []*JSONWriter
+	for _, stream := range []*JSONWriter{e.json, e.jsonl, e.rows} {
+		if stream != nil {
+			streams = append(streams, stream)
+		}
+	}
+	return streams
+}
+
+// Close closes the files without writing the table formats. JSON files are left valid, and the
+// rows for the table formats are kept so a resumed export can still write them. Safe to call more than once
+func (e *Exporter[T]) Close() error {
+	var firstErr error
+	for _, stream := range e.Streams() {
+		if err := stream.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Abort closes the files of an export that can not be resumed, removing the rows kept for the table formats.
+// Commands defer it right after NewExporter: if they stop early the JSON files are still left valid, and once
+// Finish has run it does nothing
+func (e *Exporter[T]) Abort() error {
+	err := e.Close()
+	if e.rows != nil {
+		os.Remove(e.rows.Path())
+	}
+	return err
+}
+
+// Finish closes the files and writes the table formats
+func (e *Exporter[T]) Finish() error {
+	if err := e.Close(); err != nil {
+		return err
+	}
+	if e.rows == nil {
+		return nil
+	}
+
+	if err := writeTables(e.rows.Path(), e.OutputLoc, e.Formats, e.table.Headers); err != nil {
+		return err
+	}
+	return os.Remove(e.rows.Path())
+}
+
+func (e *Exporter[T]) hasTableFormat() bool {
+	for _, format := range e.Formats {
+		if format != FormatJSON && format != FormatJSONL {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertJSONFile writes the records of a JSON or JSON Lines export to other formats, reading them one at a time.
+// Formats that would overwrite the source file are skipped
+func ConvertJSONFile[T any](source string, outputLoc string, formats []string, table Table[T]) error {
+	var targets []string
+	for _, format := range formats {
+		if outputLoc+"."+format != source {
+			targets = append(targets, format)
+		}
+	}
+
+	exporter, err := NewExporter(outputLoc, targets, table)
+	if err != nil {
+		return err
+	}
+
+	err = EachJSONRecord(source, exporter.Write)
+	if err != nil {
+		exporter.Abort()
+		return err
+	}
+
+	return exporter.Finish()
+}
+
+// tableWriter writes the rows of one table format
+type tableWriter interface {
+	WriteRow(values []string, present []bool) error
+	Close() error
+}
+
+// writeTables turns the saved rows into every table format. It reads the rows twice: once to find every
+// attribute column and once to write them, so memory use does not grow with the size of the export
+func writeTables(rowsLoc string, outputLoc string, formats []string, headers []string) error {
+	var keys []string
+	seen := map[string]bool{}
+
+	err := EachJSONRecord(rowsLoc, func(row exportRow) error {
+		for key := range row.Attributes {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(keys) // sort a-z
+	header := append(slices.Clone(headers), keys...)
+
+	var writers []tableWriter
+	closeAll := func() {
+		for _, w := range writers {
+			w.Close()
+		}
+	}
+
+	for _, format := range formats {
+		var w tableWriter
+		var err error
+
+		switch format {
+		case FormatCSV:
+			w, err = newDelimitedWriter(outputLoc+".csv", ',', header)
+		case FormatTSV:
+			w, err = newDelimitedWriter(outputLoc+".tsv", '\t', header)
+		case FormatXLSX:
+			w, err = newXLSXWriter(outputLoc+".xlsx", header)
+		case FormatParquet:
+			w, err = newParquetWriter(outputLoc+".parquet", header)
+		default:
+			continue
+		}
+		if err != nil {
+			closeAll()
+			return err
+		}
+		writers = append(writers, w)
+	}
+
+	values := make([]string, len(header))
+	present := make([]bool, len(header))
+
+	err = EachJSONRecord(rowsLoc, func(row exportRow) error {
+		for i := range header {
+			if i < len(headers) {
+				present[i] = i < len(row.Values)
+				values[i] = ""
+				if present[i] {
+					values[i] = row.Values[i]
+				}
+				continue
+			}
+			values[i], present[i] = row.Attributes[header[i]]
+		}
+
+		for _, w := range writers {
+			if err := w.WriteRow(values, present); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		closeAll()
+		return err
+	}
+
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			closeAll()
+			return err
+		}
+	}
+	return nil
+}
+
+// delimitedWriter writes csv and tsv files
+type delimitedWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newDelimitedWriter(fileLoc string, comma rune, header []string) (*delimitedWriter, error) {
+	file, err := os.Create(fileLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &delimitedWriter{file: file, writer: csv.NewWriter(file)}
+	w.writer.Comma = comma
+
+	if err := w.writer.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *delimitedWriter) WriteRow(values []string, present []bool) error {
+	return w.writer.Write(values)
+}
+
+func (w *delimitedWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// xlsxWriter streams rows into an Excel workbook, starting a new sheet when one is full
+type xlsxWriter struct {
+	fileLoc string
+	header  []string
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	sheets  int
+	row     int
+}
+
+func newXLSXWriter(fileLoc string, header []string) (*xlsxWriter, error) {
+	w := &xlsxWriter{fileLoc: fileLoc, header: header, file: excelize.NewFile()}
+	if err := w.nextSheet(); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *xlsxWriter) nextSheet() error {
+	if w.stream != nil {
+		if err := w.stream.Flush(); err != nil {
+			return err
+		}
+	}
+
+	w.sheets++
+	sheet := "Sheet" + strconv.Itoa(w.sheets)
+	if w.sheets > 1 {
+		if _, err := w.file.NewSheet(sheet); err != nil {
+			return err
+		}
+	}
+
+	stream, err := w.file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	w.stream = stream
+	w.row = 0
+
+	return w.setRow(w.header)
+}
+
+func (w *xlsxWriter) setRow(values []string) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		row[i] = value
+	}
+	return w.stream.SetRow(cell, row)
+}
+
+func (w *xlsxWriter) WriteRow(values []string, present []bool) error {
+	if w.row >= xlsxMaxRows {
+		if err := w.nextSheet(); err != nil {
+			return err
+		}
+	}
+	return w.setRow(values)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.SaveAs(w.fileLoc)
+}
+
+// parquetWriter writes every column as an optional UTF8 string. Missing attributes are stored as nulls
+type parquetWriter struct {
+	file   *os.File
+	writer *writer.CSVWriter
+	row    []*string
+}
+
+var parquetNameCleaner = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func newParquetWriter(fileLoc string, header []string) (*parquetWriter, error) {
+	file, err := os.Create(fileLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	// column names end up in a tag list, so anything but letters, numbers and _ is replaced
+	metadata := make([]string, len(header))
+	used := map[string]int{}
+	for i, name := range header {
+		name = parquetNameCleaner.ReplaceAllString(name, "_")
+		used[name]++
+		if used[name] > 1 {
+			name = name + "_" + strconv.Itoa(used[name])
+		}
+		metadata[i] = "name=" + name + ", type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(metadata, file, parquetParallel)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &parquetWriter{file: file, writer: pw, row: make([]*string, len(header))}, nil
+}
+
+func (w *parquetWriter) WriteRow(values []string, present []bool) error {
+	for i := range values {
+		w.row[i] = nil
+		if present[i] {
+			value := values[i]
+			w.row[i] = &value
+		}
+	}
+	return w.writer.WriteString(w.row)
+}
+
+func (w *parquetWriter) Close() error {
+	if w.writer != nil {
+		err := w.writer.WriteStop()
+		w.writer = nil
+		if err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+var _ io.Closer = (*parquetWriter)(nil)
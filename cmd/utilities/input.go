@@ -0,0 +1,133 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// InputRow is one record of an input file, with every value as a string
+type InputRow struct {
+	Number int // position in the file. For csv / tsv files the header is row 1, so the first record is row 2
+	Values map[string]string
+}
+
+// ReadInputFile reads the records of a csv, tsv, JSON or JSON Lines file, along with the columns used in it
+// (in file order for csv / tsv, sorted for JSON). JSON files hold one object per record. An "attributes" object is
+// read as columns of its own, so exports from 'get' can be used as input
+func ReadInputFile(fileLoc string) ([]string, []InputRow, error) {
+	switch strings.ToLower(filepath.Ext(fileLoc)) {
+	case ".csv":
+		return readDelimitedFile(fileLoc, ',')
+	case ".tsv":
+		return readDelimitedFile(fileLoc, '\t')
+	case ".json", ".jsonl":
+		return readJSONInputFile(fileLoc)
+	}
+	return nil, nil, ValidationErrorf("%s is not a csv, tsv, json or jsonl file", fileLoc)
+}
+
+func readDelimitedFile(fileLoc string, comma rune) ([]string, []InputRow, error) {
+	file, err := os.Open(fileLoc)
+	if err != nil {
+		return nil, nil, ValidationErrorf("opening %s: %w", fileLoc, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1 // short rows are checked below, with a clearer error
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, ValidationErrorf("%s is empty", fileLoc)
+	} else if err != nil {
+		return nil, nil, ValidationErrorf("reading %s: %w", fileLoc, err)
+	}
+
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")) // Excel adds a byte order mark
+	}
+
+	var rows []InputRow
+	for number := 2; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, ValidationErrorf("reading %s: %w", fileLoc, err)
+		}
+		if len(record) > len(header) {
+			return nil, nil, ValidationErrorf("row %d of %s has %d values but the header only has %d columns", number, fileLoc, len(record), len(header))
+		}
+
+		row := InputRow{Number: number, Values: map[string]string{}}
+		for i, value := range record {
+			if header[i] != "" {
+				row.Values[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return header, rows, nil
+}
+
+func readJSONInputFile(fileLoc string) ([]string, []InputRow, error) {
+	var columns []string
+	var rows []InputRow
+	seen := map[string]bool{}
+
+	err := EachJSONRecord(fileLoc, func(record map[string]interface{}) error {
+		row := InputRow{Number: len(rows) + 1, Values: map[string]string{}}
+
+		if attributes, ok := record["attributes"].(map[string]interface{}); ok {
+			delete(record, "attributes")
+			for key, value := range attributes {
+				record[key] = value
+			}
+		}
+
+		for key, value := range record {
+			row.Values[key] = jsonInputValue(value)
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, ValidationErrorf("reading %s: %w", fileLoc, err)
+	}
+
+	slices.Sort(columns)
+	return columns, rows, nil
+}
+
+// jsonInputValue turns a JSON value into the string a csv file would hold for it
+func jsonInputValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+const jsonArrayStart = "[\n"
+
+// JSONWriter streams records into a file as a JSON array, or as JSON Lines (one record per line).
+// An array file only misses its closing bracket until Close is called, so always Close it (ie with defer)
+type JSONWriter struct {
+	path    string
+	file    *os.File
+	buf     *bufio.Writer
+	lines   bool
+	records int
+	closed  bool
+	mu      sync.Mutex
+}
+
+// CreateJSONFile creates (or empties) fileLoc and starts a new export in it
+func CreateJSONFile(fileLoc string, lines bool) (*JSONWriter, error) {
+	file, err := os.OpenFile(fileLoc, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &JSONWriter{path: fileLoc, file: file, buf: bufio.NewWriter(file), lines: lines}
+	if !lines {
+		if _, err := w.buf.WriteString(jsonArrayStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return w, w.Flush()
+}
+
+// AppendJSONFile carries on an export that was started by CreateJSONFile but never closed (ie after
+// ResumeExporter cut it back to the last complete page)
+func AppendJSONFile(fileLoc string, lines bool) (*JSONWriter, error) {
+	file, err := os.OpenFile(fileLoc, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	w := &JSONWriter{path: fileLoc, file: file, buf: bufio.NewWriter(file), lines: lines}
+	if !lines && info.Size() > int64(len(jsonArrayStart)) {
+		w.records = 1 // there are records already, so the next one needs a comma in front of it
+	}
+
+	return w, nil
+}
+
+// Write adds one record to the file
+func (w *JSONWriter) Write(record any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.lines && w.records > 0 {
+		if _, err := w.buf.WriteString(",\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := w.buf.Write(data); err != nil {
+		return err
+	}
+	if w.lines {
+		if err := w.buf.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	w.records++
+	return nil
+}
+
+// Path is the file the writer writes to
+func (w *JSONWriter) Path() string {
+	return w.path
+}
+
+// Records is the number of records written by this writer
+func (w *JSONWriter) Records() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records
+}
+
+// Flush writes buffered records to disk, ie before a checkpoint records the size of the file
+func (w *JSONWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+// Close ends the JSON array and closes the file. It is safe to call more than once
+func (w *JSONWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if !w.lines {
+		end := "]\n"
+		if w.records > 0 {
+			end = "\n]\n"
+		}
+		if _, err := w.buf.WriteString(end); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// ReadJSONFile reads every record of an export, written either as a JSON array or as JSON Lines
+func ReadJSONFile[T any](fileLoc string) ([]T, error) {
+	var records []T
+
+	err := EachJSONRecord(fileLoc, func(record T) error {
+		records = append(records, record)
+		return nil
+	})
+
+	return records, err
+}
+
+// EachJSONRecord reads the records of an export one at a time, so large files are never loaded into memory at once
+func EachJSONRecord[T any](fileLoc string, fn func(record T) error) error {
+	file, err := os.Open(fileLoc)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(reader)
+
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil { // opening bracket
+			return err
+		}
+		for decoder.More() {
+			var record T
+			if err := decoder.Decode(&record); err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		_, err := decoder.Token() // closing bracket
+		return err
+	}
+
+	for {
+		var record T
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// firstNonSpace peeks at the first character of a file that is not white space
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+			return b, reader.UnreadByte()
+		}
+	}
+}
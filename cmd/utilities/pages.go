@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// FetchPageFunc gets the page that starts at offset. It should make its request with ctx,
+// so pages that are no longer needed can be cancelled
+type FetchPageFunc func(ctx context.Context, offset int) ([]byte, error)
+
+// HandlePageFunc receives each page in offset order. Returning false stops the export (ie no more records)
+type HandlePageFunc func(offset int, resp []byte) (bool, error)
+
+type pageResult struct {
+	resp []byte
+	err  error
+}
+
+// FetchPages gets the pages from start up to (but not including) end, step records apart, and hands them to
+// handle in order. Up to concurrency pages are requested at the same time. A concurrency of 1 gets one page
+// after the other, so handle can change what the next fetch asks for (ie after_id pagination)
+func FetchPages(ctx context.Context, concurrency int, start int, end int, step int, fetch FetchPageFunc, handle HandlePageFunc) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if concurrency <= 1 {
+		for offset := start; offset < end; offset = offset + step {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			resp, err := fetch(ctx, offset)
+			if err != nil {
+				return err
+			}
+
+			more, err := handle(offset, resp)
+			if err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait() // do not return while requests are still running
+	defer cancel()
+
+	// every page gets its own result channel. The queue keeps them in offset order and,
+	// because it is bounded, keeps at most concurrency pages in flight or waiting to be written
+	queue := make(chan chan pageResult, concurrency-1)
+
+	// the producer is counted in wg too, so every Add happens before Wait can return
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+
+		for offset := start; offset < end; offset = offset + step {
+			result := make(chan pageResult, 1)
+
+			select {
+			case queue <- result:
+			case <-ctx.Done():
+				return
+			}
+			// the queue can still have room once the export has stopped, and select picks either ready case
+			if err := ctx.Err(); err != nil {
+				result <- pageResult{err: err}
+				return
+			}
+
+			wg.Add(1)
+			go func(offset int) {
+				defer wg.Done()
+				resp, err := fetch(ctx, offset)
+				result <- pageResult{resp: resp, err: err}
+			}(offset)
+		}
+	}()
+
+	offset := start
+	for result := range queue {
+		if err := ctx.Err(); err != nil {
+			return err // pages that finished before the export was stopped are not handled either
+		}
+
+		page := <-result
+		if page.err != nil {
+			return page.err
+		}
+
+		more, err := handle(offset, page.resp)
+		if err != nil || !more {
+			return err
+		}
+		offset = offset + step
+	}
+
+	return ctx.Err()
+}
+
+// WithOffset encodes params with the offset of one page, without changing params
+func WithOffset(params url.Values, offset int) string {
+	pageParams := copyValues(params)
+	pageParams.Set("offset", strconv.Itoa(offset))
+
+	return pageParams.Encode()
+}
+
+// ConcurrencyFlag reads the value of a --concurrency flag
+func ConcurrencyFlag(value string) (int, error) {
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, ValidationErrorf("%q is not a valid number for --concurrency. It must be 1 or more", value)
+	}
+	return concurrency, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchPagesStopsEarly(t *testing.T) {
+	for _, concurrency := range []int{1, 2, 8} {
+		t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+			var running atomic.Int32
+			fetch := func(ctx context.Context, offset int) ([]byte, error) {
+				running.Add(1)
+				defer running.Add(-1)
+				return []byte(strconv.Itoa(offset)), nil
+			}
+
+			stop := errors.New("stop")
+			var handled []string
+			handle := func(offset int, resp []byte) (bool, error) {
+				handled = append(handled, string(resp))
+				if offset == 30 {
+					return false, stop
+				}
+				return true, nil
+			}
+
+			err := FetchPages(context.Background(), concurrency, 0, 1000, 10, fetch, handle)
+			if !errors.Is(err, stop) {
+				t.Fatalf("got error %v, want %v", err, stop)
+			}
+			if want := []string{"0", "10", "20", "30"}; !slices.Equal(handled, want) {
+				t.Errorf("handled %v, want %v", handled, want)
+			}
+			if n := running.Load(); n != 0 {
+				t.Errorf("%d fetches still running after FetchPages returned", n)
+			}
+		})
+	}
+}
+
+func TestFetchPagesCancelled(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		fetch := func(ctx context.Context, offset int) ([]byte, error) { return nil, nil }
+
+		handled := 0
+		handle := func(offset int, resp []byte) (bool, error) {
+			handled++
+			if offset == 20 {
+				cancel()
+			}
+			return true, nil
+		}
+
+		if err := FetchPages(ctx, concurrency, 0, 1000, 10, fetch, handle); !errors.Is(err, context.Canceled) {
+			t.Fatalf("concurrency %d: got error %v, want %v", concurrency, err, context.Canceled)
+		}
+		if handled != 3 {
+			t.Errorf("concurrency %d: handled %d pages, want 3", concurrency, handled)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// ErrStopPaging can be returned from a page or record callback to stop paging without an error
+var ErrStopPaging = errors.New("stop paging")
+
+// Paginator walks through the pages of a list endpoint and decodes the records stored under Key.
+// It pages with offset by default, or with after_id when UseAfterID is set
+type Paginator[T any] struct {
+	Client   *Client
+	Endpoint string
+	ID       string
+	Key      string     // field of the response that holds the records, ie "profiles"
+	Params   url.Values // query parameters sent with every page. limit, offset and after_id are set by the paginator
+
+	Limit       int // records per request
+	GetLimit    int // stop after this many records. Defaults to all of them
+	Offset      int // offset of the first page, ie when resuming an export
+	Concurrency int // pages fetched at the same time. Only used with offset pagination
+
+	UseAfterID bool   // page with the after_id of the previous page instead of an offset
+	AfterID    string // after_id of the first page. Empty starts from the first record
+
+	// Fetch gets one page with the encoded params. Defaults to a GET of Endpoint (and ID)
+	Fetch func(ctx context.Context, params string) ([]byte, error)
+}
+
+// Page is one response of a list endpoint
+type Page[T any] struct {
+	Offset      int    // offset of this page
+	NextOffset  int    // offset of the page after this one
+	NextAfterID string // after_id of the page after this one, when using after_id pagination
+	Last        bool   // no pages are fetched after this one
+	Records     []T
+	Body        []byte // the full response, for anything that is not in Records
+}
+
+// NewPaginator creates a Paginator for an endpoint of the client
+func NewPaginator[T any](client *Client, endpoint string, key string, params url.Values) *Paginator[T] {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	return &Paginator[T]{
+		Client:      client,
+		Endpoint:    endpoint,
+		Key:         key,
+		Params:      params,
+		Limit:       100,
+		GetLimit:    math.MaxInt,
+		Concurrency: 1,
+	}
+}
+
+// Total makes a single small request to find out how many records the query matches
+func (p *Paginator[T]) Total(ctx context.Context) (int, error) {
+	params := p.copyParams()
+	params.Set("metadata", "true")
+	params.Set("limit", "1")
+	params.Set("offset", "0")
+
+	resp, err := p.fetch(ctx, params.Encode())
+	if err != nil {
+		return 0, err
+	}
+
+	var metadata struct {
+		Metadata struct {
+			Total int `json:"total"`
+		} `json:"_metadata"`
+	}
+	if err := json.Unmarshal(resp, &metadata); err != nil {
+		return 0, err
+	}
+
+	return metadata.Metadata.Total, nil
+}
+
+// Pages calls fn with each page in order. Paging stops at GetLimit, at the first empty page,
+// when fn returns an error (ErrStopPaging stops without one) or when ctx is cancelled
+func (p *Paginator[T]) Pages(ctx context.Context, fn func(page Page[T]) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	end := p.GetLimit
+	if end <= 0 {
+		return nil // nothing to get
+	}
+	if end < limit {
+		limit = end
+	}
+
+	params := p.copyParams()
+	params.Set("limit", strconv.Itoa(limit))
+	params.Del("offset")
+	params.Del("after_id")
+
+	concurrency := p.Concurrency
+	afterID := p.AfterID
+	if p.UseAfterID {
+		concurrency = 1 // each after_id comes from the page before it
+	}
+
+	fetchPage := func(ctx context.Context, offset int) ([]byte, error) {
+		if p.UseAfterID {
+			pageParams := copyValues(params)
+			pageParams.Set("after_id", afterID)
+			return p.fetch(ctx, pageParams.Encode())
+		}
+		return p.fetch(ctx, WithOffset(params, offset))
+	}
+
+	handlePage := func(offset int, resp []byte) (bool, error) {
+		records, lastID, err := p.decode(resp)
+		if err != nil {
+			return false, err
+		}
+		if len(records) == 0 {
+			return false, nil
+		}
+
+		if offset+len(records) > end {
+			records = records[:end-offset]
+		}
+
+		page := Page[T]{
+			Offset:     offset,
+			NextOffset: offset + limit,
+			Last:       offset+limit >= end,
+			Records:    records,
+			Body:       resp,
+		}
+
+		if p.UseAfterID {
+			afterID = nextAfterID(resp, lastID)
+			page.NextAfterID = afterID
+			if afterID == "" {
+				page.Last = true
+			}
+		}
+
+		if err := fn(page); err != nil {
+			if errors.Is(err, ErrStopPaging) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		return !page.Last, nil
+	}
+
+	return FetchPages(ctx, concurrency, p.Offset, end, limit, fetchPage, handlePage)
+}
+
+// Each calls fn with every record, in order
+func (p *Paginator[T]) Each(ctx context.Context, fn func(record T) error) error {
+	return p.Pages(ctx, func(page Page[T]) error {
+		for _, record := range page.Records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// All collects every record into one slice. Only use it for lists that comfortably fit in memory
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var all []T
+
+	err := p.Pages(ctx, func(page Page[T]) error {
+		all = append(all, page.Records...)
+		return nil
+	})
+
+	return all, err
+}
+
+func (p *Paginator[T]) fetch(ctx context.Context, params string) ([]byte, error) {
+	if p.Fetch != nil {
+		return p.Fetch(ctx, params)
+	}
+	return p.Client.WithContext(ctx).Get(p.Endpoint, p.ID, params)
+}
+
+func (p *Paginator[T]) copyParams() url.Values {
+	return copyValues(p.Params)
+}
+
+// decode pulls the records out of a page, along with the ID of the last one (used when after_id is missing)
+func (p *Paginator[T]) decode(resp []byte) ([]T, string, error) {
+	if len(bytes.TrimSpace(resp)) == 0 {
+		return nil, "", nil // some endpoints answer a 200 with an empty body when nothing is found
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(resp, &body); err != nil {
+		return nil, "", err
+	}
+
+	raw, ok := body[p.Key]
+	if !ok {
+		return nil, "", nil
+	}
+
+	var records []T
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, "", err
+	}
+
+	var ids []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &ids); err != nil || len(ids) == 0 {
+		return records, "", nil
+	}
+
+	return records, ids[len(ids)-1].ID, nil
+}
+
+// nextAfterID reads the after_id from the metadata of a page. The API sometimes sends "null" or nothing,
+// so the ID of the last record is used instead
+func nextAfterID(resp []byte, lastID string) string {
+	var metadata struct {
+		Metadata struct {
+			AfterID interface{} `json:"after_id"`
+		} `json:"_metadata"`
+	}
+
+	if err := json.Unmarshal(resp, &metadata); err == nil {
+		if afterID, ok := metadata.Metadata.AfterID.(string); ok && afterID != "" && afterID != "null" {
+			return afterID
+		}
+	}
+
+	return lastID
+}
+
+func copyValues(values url.Values) url.Values {
+	copied := url.Values{}
+	for key, value := range values {
+		copied[key] = append([]string(nil), value...)
+	}
+	return copied
+}
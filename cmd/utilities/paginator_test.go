@@ -0,0 +1,222 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	ID string `json:"id"`
+}
+
+// recordServer answers list requests for total records named r0, r1, ... with offset or after_id paging.
+// It sends after_id as "null" so the paginator has to fall back to the last ID of each page
+type recordServer struct {
+	total int
+	delay func(offset int) time.Duration // slows down some pages, to check they are still handled in order
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (s *recordServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.mu.Unlock()
+
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		if afterID := query.Get("after_id"); afterID != "" {
+			index, err := strconv.Atoi(afterID[1:])
+			if err != nil {
+				t.Errorf("bad after_id %q", afterID)
+			}
+			offset = index + 1
+		}
+
+		if s.delay != nil {
+			time.Sleep(s.delay(offset))
+		}
+
+		records := []testRecord{}
+		for i := offset; i < min(offset+limit, s.total); i++ {
+			records = append(records, testRecord{ID: "r" + strconv.Itoa(i)})
+		}
+
+		body := map[string]any{"records": records}
+		if query.Get("metadata") == "true" || query.Has("after_id") {
+			body["_metadata"] = map[string]any{"total": s.total, "after_id": "null"}
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func (s *recordServer) paginator(t *testing.T) *Paginator[testRecord] {
+	client := newTestClient(t, s.handler(t))
+	return NewPaginator[testRecord](client, "records", "records", nil)
+}
+
+func recordIDs(records []testRecord) []string {
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func wantIDs(from int, to int) []string {
+	var ids []string
+	for i := from; i < to; i++ {
+		ids = append(ids, "r"+strconv.Itoa(i))
+	}
+	return ids
+}
+
+func TestPaginator(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       int
+		limit       int
+		getLimit    int
+		offset      int
+		afterID     bool
+		concurrency int
+		want        []string
+		requests    int
+	}{
+		{name: "all records", total: 25, limit: 10, want: wantIDs(0, 25), requests: 4},
+		{name: "get_limit below total", total: 25, limit: 10, getLimit: 15, want: wantIDs(0, 15), requests: 2},
+		{name: "get_limit equal to total", total: 25, limit: 10, getLimit: 25, want: wantIDs(0, 25), requests: 3},
+		{name: "get_limit above total", total: 25, limit: 10, getLimit: 40, want: wantIDs(0, 25), requests: 4},
+		{name: "get_limit below limit", total: 25, limit: 10, getLimit: 4, want: wantIDs(0, 4), requests: 1},
+		{name: "starting offset", total: 25, limit: 10, offset: 20, want: wantIDs(20, 25), requests: 2},
+		{name: "empty first page", total: 0, limit: 10, want: nil, requests: 1},
+		{name: "after_id null uses the last ID", total: 25, limit: 10, afterID: true, want: wantIDs(0, 25), requests: 4},
+		{name: "after_id with get_limit", total: 25, limit: 10, getLimit: 12, afterID: true, want: wantIDs(0, 12), requests: 2},
+		{name: "concurrency", total: 95, limit: 10, concurrency: 4, want: wantIDs(0, 95)},
+		{name: "concurrency with get_limit", total: 95, limit: 10, getLimit: 42, concurrency: 4, want: wantIDs(0, 42)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &recordServer{total: test.total}
+			if test.concurrency > 1 {
+				// earlier pages answer last, so they only come out in order if the paginator keeps them in order
+				server.delay = func(offset int) time.Duration { return time.Duration(100-offset) * time.Millisecond / 10 }
+			}
+
+			pager := server.paginator(t)
+			pager.Limit = test.limit
+			pager.Offset = test.offset
+			pager.UseAfterID = test.afterID
+			if test.getLimit > 0 {
+				pager.GetLimit = test.getLimit
+			}
+			if test.concurrency > 0 {
+				pager.Concurrency = test.concurrency
+			}
+
+			records, err := pager.All(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordIDs(records); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if test.requests > 0 && server.requests != test.requests {
+				t.Errorf("made %d requests, want %d", server.requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestPaginatorTotal(t *testing.T) {
+	server := &recordServer{total: 42}
+	total, err := server.paginator(t).Total(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 {
+		t.Errorf("Total = %d, want 42", total)
+	}
+}
+
+func TestPaginatorStopPaging(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		server := &recordServer{total: 100}
+		pager := server.paginator(t)
+		pager.Limit = 10
+		pager.Concurrency = concurrency
+
+		var ids []string
+		err := pager.Each(context.Background(), func(record testRecord) error {
+			if record.ID == "r23" {
+				return ErrStopPaging
+			}
+			ids = append(ids, record.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("concurrency %d: got %v, want no error", concurrency, err)
+		}
+		if want := wantIDs(0, 23); !slices.Equal(ids, want) {
+			t.Errorf("concurrency %d: got %v, want %v", concurrency, ids, want)
+		}
+	}
+}
+
+func TestPaginatorError(t *testing.T) {
+	server := &recordServer{total: 100}
+	pager := server.paginator(t)
+	pager.Limit = 10
+
+	failed := errors.New("failed")
+	pages := 0
+	err := pager.Pages(context.Background(), func(page Page[testRecord]) error {
+		pages++
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if pages != 1 {
+		t.Errorf("got %d pages, want 1", pages)
+	}
+}
+
+func TestPaginatorCancelled(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		server := &recordServer{total: 1000}
+		pager := server.paginator(t)
+		pager.Limit = 10
+		pager.Concurrency = concurrency
+
+		ctx, cancel := context.WithCancel(context.Background())
+		pages := 0
+		err := pager.Pages(ctx, func(page Page[testRecord]) error {
+			pages++
+			if pages == 3 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("concurrency %d: got %v, want %v", concurrency, err, context.Canceled)
+		}
+		if pages != 3 {
+			t.Errorf("concurrency %d: got %d pages, want 3", concurrency, pages)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that spaces out requests to a tenant. Every Client for the same
+// tenant shares one, so concurrent requests and separate commands in one run stay under the limit
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens added per second. 0 or less means no limit
+	burst       float64 // most tokens the bucket can hold
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time // set when the API says the limit has been used up
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*RateLimiter{}
+)
+
+// NewRateLimiter creates a limiter that allows requestsPerSecond on average, with bursts of up to burst requests
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SharedRateLimiter returns the limiter for a tenant host, creating it the first time it is asked for
+func SharedRateLimiter(host string, requestsPerSecond float64, burst int) *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	limiter, ok := limiters[host]
+	if !ok {
+		limiter = NewRateLimiter(requestsPerSecond, burst)
+		limiters[host] = limiter
+	}
+	return limiter
+}
+
+// Wait blocks until a request may be sent, or the context is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	wait := l.reserve()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if l.pausedUntil.After(now) {
+		wait = l.pausedUntil.Sub(now)
+	}
+
+	if l.rate <= 0 {
+		return wait
+	}
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+
+	if l.tokens < 0 {
+		tokenWait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		if tokenWait > wait {
+			wait = tokenWait
+		}
+	}
+	return wait
+}
+
+// Observe reads the rate limit headers of a response. When the API says no requests are left,
+// or sends a Retry-After, every request to the tenant is held back until the limit resets
+func (l *RateLimiter) Observe(header http.Header) {
+	if header == nil {
+		return
+	}
+
+	var until time.Time
+	if wait, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		until = time.Now().Add(wait)
+	}
+
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil || remaining > 0 {
+			continue
+		}
+		if reset, ok := parseRateLimitReset(header.Get(prefix + "Reset")); ok && reset.After(until) {
+			until = reset
+		}
+	}
+
+	if until.IsZero() {
+		return
+	}
+
+	l.mu.Lock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	l.mu.Unlock()
+}
+
+// parseRateLimitReset reads a reset value given either as seconds from now or as a unix timestamp
+func parseRateLimitReset(value string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+
+	now := time.Now()
+	if seconds > now.Unix()-86400 { // large values are timestamps, not a number of seconds
+		return time.Unix(seconds, 0), true
+	}
+
+	wait := time.Duration(seconds) * time.Second
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return now.Add(wait), true
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// ObjectKind is a type of object that flags can name instead of giving its ID
+type ObjectKind struct {
+	Name     string // used in messages, ie "profile type"
+	Endpoint string
+	Key      string // field of the list response that holds the objects
+}
+
+var (
+	ProfileTypes     = ObjectKind{Name: "profile type", Endpoint: "profile_types", Key: "profile_types"}
+	Attributes       = ObjectKind{Name: "attribute", Endpoint: "ne_attributes", Key: "ne_attributes"}
+	Workflows        = ObjectKind{Name: "workflow", Endpoint: "workflows", Key: "workflows"}
+	AdvancedSearches = ObjectKind{Name: "advanced search", Endpoint: "advanced_search", Key: "advanced_search"}
+	RiskLevels       = ObjectKind{Name: "risk level", Endpoint: "risk_levels", Key: "risk_levels"}
+)
+
+// NamedObject is the part of a profile type, attribute, workflow or advanced search needed to find it by name.
+// Profile types and workflows have a name, attributes and advanced searches have a label
+type NamedObject struct {
+	ID    string `json:"id"`
+	UID   string `json:"uid"`
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// DisplayName is the name or label of the object
+func (o NamedObject) DisplayName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Label
+}
+
+// ObjectNames returns the name (or label) of each object of a kind, by ID
+func ObjectNames(ctx context.Context, client *Client, kind ObjectKind) (map[string]string, error) {
+	objects, err := ListObjects(ctx, client, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, object := range objects {
+		names[object.ID] = object.DisplayName()
+	}
+	return names, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ListObjects returns every object of a kind in the client's tenant
+func ListObjects(ctx context.Context, client *Client, kind ObjectKind) ([]NamedObject, error) {
+	if kind == Attributes {
+		// attributes are stored with all of their fields for FindAttribute
+		attributes, err := ListAttributes(ctx, client, "")
+		objects := make([]NamedObject, len(attributes))
+		for i, attribute := range attributes {
+			objects[i] = NamedObject{ID: attribute.ID, UID: attribute.UID, Label: attribute.Label}
+		}
+		return objects, err
+	}
+
+	return cachedList[NamedObject](ctx, client, kind)
+}
+
+// ResolveID turns the value of a flag into an ID. The value can be an ID, a UID, or a name or label (ignoring case).
+// UUIDs are returned as they are without a request. A name that matches more than one object is an error that
+// lists each of them
+func ResolveID(ctx context.Context, client *Client, kind ObjectKind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || uuidPattern.MatchString(value) {
+		return value, nil
+	}
+
+	if kind == Attributes {
+		attribute, err := FindAttribute(ctx, client, value, "")
+		return attribute.ID, err
+	}
+
+	objects, err := ListObjects(ctx, client, kind)
+	if err != nil {
+		return "", err
+	}
+
+	matches := matchObjects(objects, value)
+	if len(matches) == 0 && refreshCachedList(ctx, client, kind) {
+		// the object may have been created after the cache was filled
+		if objects, err = ListObjects(ctx, client, kind); err != nil {
+			return "", err
+		}
+		matches = matchObjects(objects, value)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", ValidationErrorf("no %s has the ID, UID or name %q", kind.Name, value)
+	case 1:
+		return matches[0].ID, nil
+	}
+
+	candidates := make([]string, len(matches))
+	for i, object := range matches {
+		candidates[i] = object.DisplayName() + " (uid: " + object.UID + ", id: " + object.ID + ")"
+	}
+	return "", ValidationErrorf("more than one %s matches %q. Use the ID of one of them:\n  %s", kind.Name, value, strings.Join(candidates, "\n  "))
+}
+
+// matchObjects finds the objects with the ID, UID or name of value. A matching ID or UID wins over names
+func matchObjects(objects []NamedObject, value string) []NamedObject {
+	var byUID, byName []NamedObject
+	for _, object := range objects {
+		switch {
+		case object.ID == value:
+			return []NamedObject{object}
+		case object.UID == value:
+			byUID = append(byUID, object)
+		case strings.EqualFold(object.DisplayName(), value):
+			byName = append(byName, object)
+		}
+	}
+
+	if len(byUID) > 0 {
+		return byUID
+	}
+	return byName
+}
+
+// ResolveIDs resolves each value of a comma separated list
+func ResolveIDs(ctx context.Context, client *Client, kind ObjectKind, values string) ([]string, error) {
+	var ids []string
+	for _, value := range strings.Split(values, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		id, err := ResolveID(ctx, client, kind, value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
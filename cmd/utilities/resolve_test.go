@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+const testWorkflowID = "1234abcd-1234-abcd-5678-12345abcd5678"
+
+// workflowServer lists one workflow, or none until created is set
+func workflowServer(t *testing.T, created *atomic.Bool, pulls *atomic.Int32) *Client {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		workflows := []NamedObject{}
+		if r.URL.Query().Get("offset") == "0" {
+			pulls.Add(1)
+			workflows = append(workflows, NamedObject{ID: "1", UID: "onboard", Name: "Onboard"})
+			if created.Load() {
+				workflows = append(workflows, NamedObject{ID: testWorkflowID, UID: "new_contractor", Name: "New Contractor"})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"workflows": workflows})
+	})
+	client.Environment = "test"
+	return client
+}
+
+func TestResolveIDRefreshesCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var created atomic.Bool
+	var pulls atomic.Int32
+	client := workflowServer(t, &created, &pulls)
+
+	// fills the metadata cache before the workflow exists
+	if id, err := ResolveID(context.Background(), client, Workflows, "onboard"); err != nil || id != "1" {
+		t.Fatalf("got %q, %v, want 1", id, err)
+	}
+
+	// a new run reads the list from the metadata cache
+	lookups.Lock()
+	delete(lookups.lists, client.Host+"|"+Workflows.Endpoint)
+	lookups.Unlock()
+	created.Store(true)
+
+	id, err := ResolveID(context.Background(), client, Workflows, "new contractor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != testWorkflowID {
+		t.Errorf("got %q, want %q", id, testWorkflowID)
+	}
+	if got := pulls.Load(); got != 2 {
+		t.Errorf("pulled the workflows %d times, want 2", got)
+	}
+
+	// the list was just pulled, so a name that does not exist is not pulled again
+	if _, err := ResolveID(context.Background(), client, Workflows, "Offboard"); ExitCode(err) != ExitValidation {
+		t.Errorf("got %v, want a validation error", err)
+	}
+	if got := pulls.Load(); got != 2 {
+		t.Errorf("pulled the workflows %d times, want 2", got)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package utilities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetriesRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	start := time.Now()
+	if _, err := client.Get("profiles", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %s, want the 1s from Retry-After", waited)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"profiles":[]}`))
+		}
+	})
+
+	resp, err := client.Get("profiles", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != `{"profiles":[]}` {
+		t.Errorf("response = %s", resp)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestClientDoesNotRetryWrites(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		t.Run(method, func(t *testing.T) {
+			var calls atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+
+			var err error
+			if method == http.MethodPost {
+				_, err = client.Post("profiles", "", []byte(`{}`))
+			} else {
+				_, err = client.Patch("profiles", "abc", []byte(`{}`))
+			}
+
+			if ExitCode(err) != ExitServer {
+				t.Errorf("got %v, want a server error", err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("got %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+func TestClientMaxRetries(t *testing.T) {
+	for _, maxRetries := range []int{0, 1, 3} {
+		var calls atomic.Int32
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		client.MaxRetries = maxRetries
+
+		_, err := client.Get("profiles", "", "")
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("max retries %d: got %v, want the last 503", maxRetries, err)
+		}
+		if got := calls.Load(); got != int32(maxRetries+1) {
+			t.Errorf("max retries %d: got %d requests, want %d", maxRetries, got, maxRetries+1)
+		}
+	}
+}
+
+func TestClientCancelledDuringBackoff(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client.RetryWaitMin = time.Minute
+	client.RetryWaitMax = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.WithContext(ctx).Get("profiles", "", "")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("returned after %s, want it to stop waiting once cancelled", waited)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
@@ -5,9 +5,8 @@ package utilities
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"nerm/cmd/configs"
 	"net/http"
@@ -105,9 +104,12 @@ func CreateEnvironment(environmentName string) error {
 	configs.SetCurrentEnvironment(environmentName)
 	configs.SetTenant(tenant)
 	configs.SetBaseURL(baseurl)
-	configs.SetAPIToken(tenant+"."+baseurl,tenant,token)
+	if err := configs.SetAPIToken(tenant+"."+baseurl, tenant, token); err != nil {
+		return fmt.Errorf("storing the token for %s in the keyring: %w", tenant, err)
+	}
 
-	return nil
+	// a new environment can reuse the name of one that was deleted
+	return ClearCache(strings.ToLower(environmentName))
 }
 
 func UpdateEnvironment(environmentName string) error {
@@ -166,135 +168,33 @@ func UpdateEnvironment(environmentName string) error {
 	configs.SetCurrentEnvironment(environmentName)
 	configs.SetTenant(tenant)
 	configs.SetBaseURL(baseurl)
-	configs.SetAPIToken(tenant+"."+baseurl,tenant,token)
+	if err := configs.SetAPIToken(tenant+"."+baseurl, tenant, token); err != nil {
+		return fmt.Errorf("storing the token for %s in the keyring: %w", tenant, err)
+	}
 
-	return nil
+	// the cached lists may belong to the old tenant
+	return ClearCache(strings.ToLower(environmentName))
 }
 
+// MakeAPIRequests makes a request against the current environment. Commands should prefer a Client from ClientFromContext
 func MakeAPIRequests(method string, endpoint string, req_id string, params string, jsonStr []byte) ([]byte, error) {
-	tenant := configs.GetTenant()
-	baseurl := configs.GetBaseURL()
-
-	url := "https://" + tenant + "." + baseurl + "/api/" + endpoint
-	if req_id != "" {
-		url = url + "/" + req_id + "?" + params
-	} else {
-		url = url + "?" + params
+	client, err := ClientFromContext(context.Background())
+	if err != nil {
+		return nil, err
 	}
 
 	switch strings.ToLower(method) {
 	case "get":
-		// fmt.Println("url: ", url, " |   jsonstr: ", jsonStr)
-		resp, resp_err := MakeGetRequest(url, jsonStr)
-
-		return resp, resp_err
+		return client.Get(endpoint, req_id, params)
 	case "post":
-		resp, resp_err := MakePostRequest(url, jsonStr)
-
-		return resp, resp_err
-
+		return client.Do(http.MethodPost, client.URL(endpoint, req_id, params), jsonStr)
 	case "patch":
-		resp, resp_err := MakePatchRequest(url, jsonStr)
-
-		return resp, resp_err
-
+		return client.Do(http.MethodPatch, client.URL(endpoint, req_id, params), jsonStr)
 	case "delete":
-
-	default:
-		fmt.Println("No Method given.")
-	}
-
-	return nil, nil
-}
-
-func MakePatchRequest(url string, jsonStr []byte) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(jsonStr))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	req.Header.Add("Authorization", configs.GetAPIToken())
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	CheckError(err)
-
-	respBody, err := io.ReadAll(resp.Body)
-	CheckError(err)
-	defer resp.Body.Close()
-
-	return respBody, nil
-}
-
-func MakePostRequest(url string, jsonStr []byte) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonStr))
-	if err != nil {
-		log.Fatal(err)
+		return client.Delete(endpoint, req_id, params)
 	}
 
-	req.Header.Add("Authorization", configs.GetAPIToken())
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	CheckError(err)
-
-	respBody, err := io.ReadAll(resp.Body)
-	CheckError(err)
-	defer resp.Body.Close()
-
-	return respBody, nil
-}
-
-func MakeGetRequest(url string, jsonStr []byte) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, url, bytes.NewBuffer(jsonStr))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	req.Header.Add("Authorization", configs.GetAPIToken())
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	CheckError(err)
-
-	respBody, err := io.ReadAll(resp.Body)
-	CheckError(err)
-	defer resp.Body.Close()
-
-	return respBody, nil
-}
-
-func RunAdvSearchRequest(req_id string, params string) ([]byte, error) {
-	tenant := configs.GetTenant()
-	baseurl := configs.GetBaseURL()
-	url := "https://" + tenant + "." + baseurl + "/api/advanced_search/" + req_id + "/run?" + params
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	req.Header.Add("Authorization", configs.GetAPIToken())
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	CheckError(err)
-
-	respBody, err := io.ReadAll(resp.Body)
-	CheckError(err)
-	defer resp.Body.Close()
-
-	return respBody, nil
-}
-
-func CheckError(err error) {
-	if err != nil {
-		log.Fatal(err)
-	}
+	return nil, fmt.Errorf("unsupported request method %q", method)
 }
 
 /*
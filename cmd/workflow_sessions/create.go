@@ -1,178 +1,338 @@
 /*
 Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 */
-
-// CLONED FROM GET - NEEDS TO BE UPDATED STILL
 package workflow_sessions
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
 	"nerm/cmd/configs"
 	"nerm/cmd/utilities"
-	"net/url"
+	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
+// sessionFields are columns of a session export that are not attributes. They are skipped when creating sessions,
+// so an export from 'sessions get' can be used as the input file
+var sessionFields = []string{"id", "uid", "workflowid", "workflow_id", "requestertype", "requester_type", "requesterid", "requester_id", "status", "updatedat", "updated_at", "createdat", "created_at",
+	"workflowname", "workflow_name", "profilename", "profile_name", "requestername", "requester_name", "requesterlogin", "requester_login"}
+
+// profileColumns are the columns that can hold the profile a session is run for
+var profileColumns = []string{"profile_id", "profileid"}
+
+// sessionCreateResult is one row of the results file written by 'sessions create'
+type sessionCreateResult struct {
+	Row        int               `json:"row"`
+	Result     string            `json:"result"` // created, failed or invalid
+	ID         string            `json:"id,omitempty"`
+	ProfileID  string            `json:"profile_id,omitempty"`
+	Status     string            `json:"status,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+var sessionCreateResultTable = utilities.Table[sessionCreateResult]{
+	Headers: []string{"Row", "Result", "ID", "ProfileID", "Status", "Error"},
+	Row: func(r sessionCreateResult) ([]string, map[string]string) {
+		return []string{strconv.Itoa(r.Row), r.Result, r.ID, r.ProfileID, r.Status, r.Error}, r.Attributes
+	},
+}
+
 func newSessionsCreateCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "Create",
-		Short:   "Create Workflow Sessions",
-		Long:    "Uses data from a JSON or CSV file to generate workflow sessions in the current tenant",
-		Example: "nerm sessions create -f something.csv ",
-		Aliases: []string{"g"},
+		Use:     "create",
+		Short:   "Creates Workflow Sessions from a CSV or JSON file",
+		Long:    "Runs a workflow for each row of a CSV, TSV, JSON or JSON Lines file. Each column is matched to an attribute of the workflow's profile type by its UID or label, and a 'profile_id' column sets the profile the workflow is run for. Every row is checked before anything is sent. Writes a results file with the ID of each new session, or why its row failed",
+		Example: "nerm sessions create -w \"New Contractor\" -f contractors.csv | nerm sessions create -w 1234abcd-1234-abcd-5678-12345abcd5678 -f updates.json --batch_size 20",
+		Aliases: []string{"c"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
 
-			var resp []byte
-			var requestErr error
-			var days int
-			var err error
-
-			params := url.Values{}
-
-			id := cmd.Flags().Lookup("id").Value.String()
-			uid := cmd.Flags().Lookup("uid").Value.String()
-			profile_id := cmd.Flags().Lookup("profile_id").Value.String()
-			status := cmd.Flags().Lookup("status").Value.String()
+			file := cmd.Flags().Lookup("file").Value.String()
 			workflow_id := cmd.Flags().Lookup("workflow_id").Value.String()
-			requester_id := cmd.Flags().Lookup("requester_id").Value.String()
-			limit := cmd.Flags().Lookup("limit").Value.String()
-			getLimit := cmd.Flags().Lookup("get_limit").Value.String()
-
-			dayString := cmd.Flags().Lookup("days").Value.String()
+			profile_id := cmd.Flags().Lookup("profile_id").Value.String()
+			batchSize := cmd.Flags().Lookup("batch_size").Value.String()
 
-			if dayString != "" {
-				days, err = strconv.Atoi(dayString)
-				utilities.CheckError(err)
-				params.Add("order", "created_at DESC")
+			batchSizeInt, err := strconv.Atoi(batchSize)
+			if err != nil || batchSizeInt < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number for --batch_size", batchSize)
 			}
-
-			limitInt, _ := strconv.Atoi(limit)
-
-			var getLimitInt int
-
-			if getLimit != "" {
-				getLimitInt, _ = strconv.Atoi(getLimit)
-			} else {
-				getLimitInt = math.MaxInt
+			if batchSizeInt > 100 {
+				fmt.Println("Batch size can not be over 100 - Setting it back down to 100")
+				batchSizeInt = 100
 			}
 
-			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Sessions_Export" + strconv.Itoa(int(time.Now().Unix()))
-
-			createSessionsJsonFile(outputLoc + ".json")
-
-			params.Add("metadata", "true") //  include metadata for limit/offsets
-
-			// add params that have been set
-			if uid != "" {
-				params.Add("uid", uid)
-			}
-			if profile_id != "" {
-				params.Add("profile_id", profile_id)
+			workflow_id, err = utilities.ResolveID(cmd.Context(), client, utilities.Workflows, workflow_id)
+			if err != nil {
+				return err
 			}
-			if status != "" {
-				params.Add("status", status)
+
+			// make sure the workflow exists, and find the profile type its attributes belong to
+			workflowResp, err := client.Get("workflows", workflow_id, "")
+			if err != nil {
+				return err
 			}
-			if workflow_id != "" {
-				params.Add("workflow_id", workflow_id)
+			var workflow struct {
+				Workflow struct {
+					Name          string `json:"name"`
+					ProfileTypeID string `json:"profile_type_id"`
+				} `json:"workflow"`
 			}
-			if requester_id != "" {
-				params.Add("requester_id", requester_id)
+			if err := json.Unmarshal(workflowResp, &workflow); err != nil {
+				return fmt.Errorf("can not unmarshal workflow: %w", err)
 			}
+			workflowName := workflow.Workflow.Name
 
-			// make first call to get the total number of sessisions to be returned
-			params.Add("limit", "1")
-			resp, requestErr = utilities.MakeAPIRequests("get", "workflow_sessions", id, params.Encode(), nil)
-			if limitInt > 500 {
-				fmt.Println("Limit can not be over 500")
-				limit = "500"
-				params.Set("limit", "500")
-			} else {
-				params.Set("limit", limit)
+			// workflows that are not tied to a profile type can use the attributes of any of them
+			attributes, err := utilities.ListAttributes(cmd.Context(), client, workflow.Workflow.ProfileTypeID)
+			if err != nil {
+				return err
 			}
-			utilities.CheckError(requestErr)
 
-			var sessions_result SessionResponse
-			var respMetaData ResponseMetaData
+			columns, rows, err := utilities.ReadInputFile(file)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return utilities.ValidationErrorf("%s has no rows to create sessions from", file)
+			}
 
-			err = json.Unmarshal(resp, &sessions_result)
-			utilities.CheckError(err)
+			columnUIDs, profileColumn, err := mapSessionColumns(columns, attributes, workflowName)
+			if err != nil {
+				return err
+			}
 
-			err = json.Unmarshal(resp, &respMetaData)
-			utilities.CheckError(err)
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Sessions_Create" + strconv.Itoa(int(time.Now().Unix()))
 
-			if getLimitInt > respMetaData.Metadata.Total {
-				// getLimit = strconv.Itoa(respMetaData.Metadata.Total)
-				getLimitInt = respMetaData.Metadata.Total
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), sessionCreateResultTable)
+			if err != nil {
+				return err
 			}
+			defer output.Abort()
 
-			params.Set("metadata", "false") // metadata makes calls slow, we do not need it for the actual data gets
-
-			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of profile types found
+			var batch []sessionCreateResult
+			var batchSessions []newSession
+			invalid := 0
 
-			lastLoop := false
+			// check every row before anything is sent, so a bad file does not leave half its sessions started
+			for _, row := range rows {
+				result := sessionCreateResult{Row: row.Number, ProfileID: profile_id, Attributes: map[string]string{}}
 
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
-				var sessions SessionResponse      // this round of sessions from Get
-				var finalSessions SessionResponse // the sessions that will be put into the file
+				for column, uid := range columnUIDs {
+					if value := row.Values[column]; value != "" {
+						result.Attributes[uid] = value
+					}
+				}
 
-				params.Add("offset", strconv.Itoa(offset))
+				if profileColumn != "" && strings.TrimSpace(row.Values[profileColumn]) != "" {
+					result.ProfileID = strings.TrimSpace(row.Values[profileColumn])
+				}
+				if len(result.Attributes) == 0 && result.ProfileID == "" {
+					result.Error = "the row has no attribute values or profile ID"
+				}
 
-				resp, requestErr = utilities.MakeAPIRequests("get", "workflow_sessions", id, params.Encode(), nil)
-				utilities.CheckError(requestErr)
+				if result.Error != "" {
+					result.Result = "invalid"
+					invalid++
+					if err := output.Write(result); err != nil {
+						return err
+					}
+					continue
+				}
 
-				err := json.Unmarshal(resp, &sessions)
-				utilities.CheckError(err)
+				batch = append(batch, result)
+				batchSessions = append(batchSessions, newSession{WorkflowID: workflow_id, ProfileID: result.ProfileID, Attributes: result.Attributes})
+			}
 
-				// err = json.Unmarshal(resp, &respMetaData)
-				// utilities.CheckError(err)
+			if invalid > 0 {
+				fmt.Println(invalid, "row(s) are not valid and will be skipped. They are listed in the results file")
+			}
+			fmt.Println("Starting", len(batch), workflowName, "session(s)")
 
-				if (offset + limitInt) >= getLimitInt {
-					bar.Set(getLimitInt)
-					lastLoop = true
-				} else {
-					bar.Add(limitInt) // increment progress
-				}
+			bar := progressbar.Default(int64(len(batch)))
+			created := 0
+			failed := 0
 
-				for _, rec := range sessions.Sessions {
-					t := time.Now().AddDate(0, 0, (days * -1))
-					compareDate := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) // zeros out the day
+			for start := 0; start < len(batch); start = start + batchSizeInt {
+				end := min(start+batchSizeInt, len(batch))
+				results := batch[start:end]
 
-					createdAtTime, dateErr := time.Parse(time.RFC3339, rec.CreatedAt)
-					utilities.CheckError(dateErr)
+				if err := createSessions(client, batchSessions[start:end], results); err != nil {
+					return err
+				}
 
-					if createdAtTime.After(compareDate) {
-						// fmt.Println("after")
-						finalSessions.Sessions = append(finalSessions.Sessions, rec)
+				for _, result := range results {
+					if result.Result == "created" {
+						created++
+					} else {
+						failed++
 					}
+					if err := output.Write(result); err != nil {
+						return err
+					}
+				}
+				if err := output.Flush(); err != nil { // keep the results of each batch on disk in case the command is stopped
+					return err
 				}
 
-				printJsonToFile(outputLoc+".json", finalSessions, lastLoop)
+				bar.Add(len(results))
 			}
 
-			endSessionsJsonFile(outputLoc + ".json")
+			if err := output.Finish(); err != nil {
+				return err
+			}
 
-			convertJSONToCSV(outputLoc+".json", outputLoc+".csv")
+			fmt.Println("\nCreated", created, "session(s).", failed, "failed and", invalid, "were not valid")
+			fmt.Println("Results stored in " + outputLoc)
 
-			fmt.Println("\n" + "Session data stored in " + outputLoc)
+			if failed+invalid > 0 {
+				return fmt.Errorf("%d of %d row(s) were not created. See %s for the errors", failed+invalid, len(rows), outputLoc)
+			}
 
 			return nil
 		},
 	}
-	cmd.Flags().StringP("id", "i", "", "ID of a specific Workflow Session")
-	cmd.Flags().StringP("uid", "u", "", "UID of a specific Workflow Session")
-	cmd.Flags().StringP("profile_id", "p", "", "Find all sessions that were run for a Profile")
-	cmd.Flags().StringP("status", "s", "", "Status of the Workflow Session")
-	cmd.Flags().StringP("workflow_id", "w", "", "ID of a specific Workflow that was run")
-	cmd.Flags().StringP("requester_id", "r", "", "Find all sessions that were run by a specific User")
-	cmd.Flags().StringP("limit", "l", strconv.Itoa(configs.GetDefaultLimitParam()), "Limit for each GET request")
-	cmd.Flags().StringP("get_limit", "g", "", "Set a Get limit for how many sessions to pull back (default is All sessions)")
-	cmd.Flags().StringP("days", "d", "", "Pull sessions from the last x days")
+
+	cmd.Flags().StringP("file", "f", "", "CSV, TSV, JSON or JSON Lines file with a row for each new session")
+	cmd.Flags().StringP("workflow_id", "w", "", "Workflow to run (ID, UID or name)")
+	cmd.Flags().StringP("profile_id", "p", "", "Profile to run the workflow for, for rows without a 'profile_id' column")
+	cmd.Flags().String("batch_size", "50", "Number of sessions to create with each request (100 at most)")
+
+	cmd.RegisterFlagCompletionFunc("workflow_id", utilities.CompleteNames(utilities.Workflows))
+
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagRequired("workflow_id")
 
 	return cmd
 }
+
+// mapSessionColumns matches each column of the input file to an attribute UID, by UID first and then by label
+// (ignoring case). It also returns the column holding the profile ID, if there is one
+func mapSessionColumns(columns []string, attributes []utilities.Attribute, workflowName string) (map[string]string, string, error) {
+	byUID := map[string]utilities.Attribute{}
+	byLabel := map[string][]utilities.Attribute{}
+	for _, attribute := range attributes {
+		byUID[attribute.UID] = attribute
+		label := strings.ToLower(attribute.Label)
+		byLabel[label] = append(byLabel[label], attribute)
+	}
+
+	columnUIDs := map[string]string{}
+	profileColumn := ""
+	var skipped, unknown, archived, ambiguous []string
+
+	for _, column := range columns {
+		if column == "" {
+			continue
+		}
+
+		attribute, found := byUID[column]
+		if !found {
+			switch matches := byLabel[strings.ToLower(column)]; len(matches) {
+			case 0:
+			case 1:
+				attribute, found = matches[0], true
+			default:
+				ambiguous = append(ambiguous, column)
+				continue
+			}
+		}
+
+		switch {
+		case !found && slices.Contains(profileColumns, strings.ToLower(column)):
+			profileColumn = column
+		case !found && slices.Contains(sessionFields, strings.ToLower(column)):
+			skipped = append(skipped, column)
+		case !found:
+			unknown = append(unknown, column)
+		case attribute.Archived:
+			archived = append(archived, column)
+		default:
+			columnUIDs[column] = attribute.UID
+		}
+	}
+
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, "not attributes of the "+workflowName+" workflow: "+strings.Join(unknown, ", "))
+	}
+	if len(archived) > 0 {
+		problems = append(problems, "archived attributes: "+strings.Join(archived, ", "))
+	}
+	if len(ambiguous) > 0 {
+		problems = append(problems, "labels used by more than one attribute (use the UID instead): "+strings.Join(ambiguous, ", "))
+	}
+	if len(problems) > 0 {
+		return nil, "", utilities.ValidationErrorf("the input file has columns that can not be used.\n  %s", strings.Join(problems, "\n  "))
+	}
+	if len(columnUIDs) == 0 && profileColumn == "" {
+		return nil, "", utilities.ValidationErrorf("none of the columns of the input file are attributes of the %s workflow or a profile_id", workflowName)
+	}
+
+	if len(skipped) > 0 {
+		fmt.Println("Skipping columns that are not attributes:", strings.Join(skipped, ", "))
+	}
+
+	return columnUIDs, profileColumn, nil
+}
+
+// createSessions sends one batch of new sessions and fills in the result of each one. If the API rejects the batch,
+// each session is sent on its own so only the rows with a problem fail
+func createSessions(client *utilities.Client, sessions []newSession, results []sessionCreateResult) error {
+	body, err := json.Marshal(map[string][]newSession{"workflow_sessions": sessions})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post("workflow_sessions", "", body)
+	if err != nil {
+		var apiErr *utilities.APIError
+		rejected := errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity)
+
+		if rejected && len(sessions) > 1 {
+			for i := range sessions {
+				if err := createSessions(client, sessions[i:i+1], results[i:i+1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for i := range results {
+			results[i].Result = "failed"
+			results[i].Error = utilities.RowError(err)
+		}
+		if client.Context().Err() != nil {
+			return client.Context().Err()
+		}
+		return nil
+	}
+
+	var created SessionResponse
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return fmt.Errorf("can not unmarshal workflow sessions: %w", err)
+	}
+
+	for i := range results {
+		if i < len(created.Sessions) && created.Sessions[i].ID != "" {
+			results[i].Result = "created"
+			results[i].ID = created.Sessions[i].ID
+			results[i].Status = created.Sessions[i].Status
+		} else {
+			results[i].Result = "failed"
+			results[i].Error = "the API did not return a session for this row"
+		}
+	}
+
+	return nil
+}
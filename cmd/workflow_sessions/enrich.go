@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package workflow_sessions
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"nerm/cmd/utilities"
+	"strings"
+)
+
+// nameLookup is one profile or user to look up, by the endpoint it is found at and its ID
+type nameLookup struct {
+	endpoint string
+	id       string
+}
+
+type resolvedName struct {
+	name  string
+	login string
+}
+
+// nameResolver adds the names of the workflow, profile and requester of each session for --human_readable. Each
+// profile and user is looked up once, however many sessions they are on, and workflows come from the metadata cache
+type nameResolver struct {
+	client      *utilities.Client
+	concurrency int
+	workflows   map[string]string
+	names       map[nameLookup]resolvedName
+	failed      int // lookups that failed for a reason other than the profile or user not existing
+}
+
+func newNameResolver(ctx context.Context, client *utilities.Client, concurrency int) (*nameResolver, error) {
+	workflows, err := utilities.ObjectNames(ctx, client, utilities.Workflows)
+	if err != nil {
+		return nil, err
+	}
+	return &nameResolver{client: client, concurrency: concurrency, workflows: workflows, names: map[nameLookup]resolvedName{}}, nil
+}
+
+// requesterLookup returns where the requester of a session is found. Sessions are requested by users (admins
+// and portal users), or by profiles
+func requesterLookup(session SessionJsonFileData) (nameLookup, bool) {
+	if session.RequesterID == "" {
+		return nameLookup{}, false
+	}
+	requesterType := strings.ToLower(session.RequesterType)
+	if strings.Contains(requesterType, "profile") && !strings.Contains(requesterType, "user") {
+		return nameLookup{"profiles", session.RequesterID}, true
+	}
+	return nameLookup{"users", session.RequesterID}, true
+}
+
+// resolve fills in the names of a page of sessions, looking up the profiles and users that have not been seen yet
+// --concurrency at a time
+func (r *nameResolver) resolve(ctx context.Context, sessions []SessionJsonFileData) error {
+	var lookups []nameLookup
+	queued := map[nameLookup]bool{}
+	queue := func(lookup nameLookup) {
+		if _, found := r.names[lookup]; !found && !queued[lookup] {
+			queued[lookup] = true
+			lookups = append(lookups, lookup)
+		}
+	}
+
+	for _, session := range sessions {
+		if session.ProfileID != "" {
+			queue(nameLookup{"profiles", session.ProfileID})
+		}
+		if requester, ok := requesterLookup(session); ok {
+			queue(requester)
+		}
+	}
+
+	failed := make([]bool, len(lookups))
+
+	fetch := func(ctx context.Context, i int) ([]byte, error) {
+		params := ""
+		if lookups[i].endpoint == "profiles" {
+			params = "exclude_attributes=true"
+		}
+		resp, err := r.client.WithContext(ctx).Get(lookups[i].endpoint, lookups[i].id, params)
+		if utilities.IsNotFound(err) {
+			return nil, nil // deleted profiles and users are left without a name
+		}
+		if err != nil && ctx.Err() == nil {
+			failed[i] = true
+			return nil, nil
+		}
+		return resp, err
+	}
+
+	handle := func(i int, resp []byte) (bool, error) {
+		lookup := lookups[i]
+		if failed[i] {
+			r.failed++
+		}
+
+		var name resolvedName
+		if len(resp) > 0 {
+			var err error
+			if name, err = decodeName(lookup, resp); err != nil {
+				return false, err
+			}
+		}
+		r.names[lookup] = name
+		return true, nil
+	}
+
+	if err := utilities.FetchPages(ctx, r.concurrency, 0, len(lookups), 1, fetch, handle); err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		sessions[i].WorkflowName = r.workflows[sessions[i].WorkflowID]
+		if sessions[i].ProfileID != "" {
+			sessions[i].ProfileName = r.names[nameLookup{"profiles", sessions[i].ProfileID}].name
+		}
+		if requester, ok := requesterLookup(sessions[i]); ok {
+			name := r.names[requester]
+			sessions[i].RequesterName, sessions[i].RequesterLogin = name.name, name.login
+		}
+	}
+
+	return nil
+}
+
+// decodeName reads the name out of a profiles/<id> or users/<id> response
+func decodeName(lookup nameLookup, resp []byte) (resolvedName, error) {
+	if lookup.endpoint == "profiles" {
+		var profile ProfileResponse
+		if err := json.Unmarshal(resp, &profile); err != nil {
+			return resolvedName{}, fmt.Errorf("can not unmarshal profile %s: %w", lookup.id, err)
+		}
+		for _, p := range append([]ProfileName{profile.Profile}, profile.Profiles...) {
+			if p.ID == lookup.id {
+				return resolvedName{name: p.Name}, nil
+			}
+		}
+		return resolvedName{}, nil
+	}
+
+	var user UserResponse
+	if err := json.Unmarshal(resp, &user); err != nil {
+		return resolvedName{}, fmt.Errorf("can not unmarshal user %s: %w", lookup.id, err)
+	}
+	for _, u := range append([]UserName{user.User}, user.Users...) {
+		if u.ID == lookup.id {
+			return resolvedName{name: u.Name, login: cmp.Or(u.Login, u.Email)}, nil
+		}
+	}
+	return resolvedName{}, nil
+}
@@ -4,12 +4,12 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package workflow_sessions
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"nerm/cmd/configs"
 	"nerm/cmd/utilities"
 	"net/url"
+	"os"
 	"strconv"
 	"time"
 
@@ -21,13 +21,16 @@ func newSessionsGetCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "get",
 		Short:   "Gets Workflow Sessions from current environment",
-		Long:    "Pulls Workflow Sessions from current environment based on query parameters. Stores data in a CSV and JSON file at the defaul output location",
+		Long:    "Pulls Workflow Sessions from current environment based on query parameters. Stores data at the defaul output location in the formats set with --format (JSON and CSV by default)",
 		Example: "nerm sessions get --status failed",
 		Aliases: []string{"g"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			var resp []byte
-			var requestErr error
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
 			var days int
 			var err error
 
@@ -42,33 +45,51 @@ func newSessionsGetCommand() *cobra.Command {
 			limit := cmd.Flags().Lookup("limit").Value.String()
 			getLimit := cmd.Flags().Lookup("get_limit").Value.String()
 			order := cmd.Flags().Lookup("order").Value.String()
+			resume := cmd.Flags().Lookup("resume").Value.String()
 
-			// humanReadable, humanReadableError := cmd.Flags().GetBool("human_readable")
-			// utilities.CheckError(humanReadableError)
+			humanReadable, _ := cmd.Flags().GetBool("human_readable")
 
 			dayString := cmd.Flags().Lookup("days").Value.String()
 
 			if dayString != "" {
 				days, err = strconv.Atoi(dayString)
-				utilities.CheckError(err)
+				if err != nil {
+					return utilities.ValidationErrorf("%q is not a valid number for --days", dayString)
+				}
 				params.Add("order", "created_at DESC")
 			} else {
 				params.Add("order", order)
 			}
 
-			limitInt, _ := strconv.Atoi(limit)
+			limitInt, err := strconv.Atoi(limit)
+			if err != nil {
+				return utilities.ValidationErrorf("%q is not a valid number for --limit", limit)
+			}
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
 
 			var getLimitInt int
 
 			if getLimit != "" {
-				getLimitInt, _ = strconv.Atoi(getLimit)
+				getLimitInt, err = strconv.Atoi(getLimit)
+				if err != nil {
+					return utilities.ValidationErrorf("%q is not a valid number for --get_limit", getLimit)
+				}
 			} else {
 				getLimitInt = math.MaxInt
 			}
 
 			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Sessions_Export" + strconv.Itoa(int(time.Now().Unix()))
 
-			createSessionsJsonFile(outputLoc + ".json")
+			var checkpoint *utilities.Checkpoint
+			var output *utilities.Exporter[SessionJsonFileData]
+			startOffset := 0
+
+			t := time.Now().AddDate(0, 0, (days * -1))
+			compareDate := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) // zeros out the day
 
 			params.Add("metadata", "true") //  include metadata for limit/offsets
 
@@ -82,6 +103,11 @@ func newSessionsGetCommand() *cobra.Command {
 			if status != "" {
 				params.Add("status", status)
 			}
+			workflow_id, err = utilities.ResolveID(cmd.Context(), client, utilities.Workflows, workflow_id)
+			if err != nil {
+				return err
+			}
+
 			if workflow_id != "" {
 				params.Add("workflow_id", workflow_id)
 			}
@@ -89,115 +115,161 @@ func newSessionsGetCommand() *cobra.Command {
 				params.Add("requester_id", requester_id)
 			}
 
-			// make first call to get the total number of sessisions to be returned
-			params.Add("limit", "1")
-			resp, requestErr = utilities.MakeAPIRequests("get", "workflow_sessions", id, params.Encode(), nil)
-			utilities.CheckError(requestErr)
-
-			// Set limit to 100 if it was over 100. Then set it to getLimit if it is lower than the definded limit
-			if limitInt > 100 {
-				fmt.Println("Limit can not be over 100")
-				limit = "100"
-			}
-			if getLimitInt < limitInt {
-				limitInt = getLimitInt
-				limit = getLimit
-			}
-			params.Set("limit", limit)
-
-			var sessions_result SessionResponse
-			var respMetaData ResponseMetaData
-
-			err = json.Unmarshal(resp, &sessions_result)
-			utilities.CheckError(err)
+			pager := utilities.NewPaginator[SessionJsonFileData](client, "workflow_sessions", "workflow_sessions", params)
+			pager.ID = id
 
-			err = json.Unmarshal(resp, &respMetaData)
-			utilities.CheckError(err)
-
-			if getLimitInt > respMetaData.Metadata.Total {
-				// getLimit = strconv.Itoa(respMetaData.Metadata.Total)
-				getLimitInt = respMetaData.Metadata.Total
-			}
+			if resume != "" {
+				// carry on from where a previous run stopped, with the same environment, parameters and files
+				checkpoint, err = utilities.LoadCheckpoint(resume, "sessions get")
+				if err != nil {
+					return err
+				}
 
-			params.Set("metadata", "false") // metadata makes calls slow, we do not need it for the actual data gets
+				resumeClient, clientErr := utilities.NewClientForEnvironment(checkpoint.Environment)
+				if clientErr != nil {
+					return clientErr
+				}
+				pager.Client = resumeClient
 
-			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of profile types found
+				pager.Params, err = url.ParseQuery(checkpoint.Params)
+				if err != nil {
+					return utilities.ValidationErrorf("reading checkpoint %s: %w", resume, err)
+				}
+				pager.ID = checkpoint.Flags["id"]
+				limitInt, _ = strconv.Atoi(checkpoint.Flags["limit"])
+				dayString = checkpoint.Flags["days"]
+				if dayString != "" {
+					compareDate, err = time.Parse(time.RFC3339, checkpoint.Flags["created_after"])
+					if err != nil {
+						return utilities.ValidationErrorf("reading checkpoint %s: %w", resume, err)
+					}
+				}
+				humanReadable = checkpoint.Flags["human_readable"] == "true"
+				getLimitInt = checkpoint.GetLimit
+				outputLoc = checkpoint.OutputLoc
+				startOffset = checkpoint.Offset
+
+				table := sessionTable
+				if humanReadable {
+					table = humanSessionTable
+				}
+				output, err = utilities.ResumeExporter(checkpoint, table)
+				if err != nil {
+					return err
+				}
+			} else {
+				// make first call to get the total number of sessisions to be returned
+				total, requestErr := pager.Total(cmd.Context())
+				if requestErr != nil {
+					return requestErr
+				}
 
-			lastLoop := false
+				// Set limit to 100 if it was over 100
+				if limitInt > 100 {
+					fmt.Println("Limit can not be over 100")
+					limitInt = 100
+				}
 
-			for offset := 0; offset < getLimitInt; offset = offset + limitInt {
-				var sessions SessionResponse      // this round of sessions from Get
-				var finalSessions SessionResponse // the sessions that will be put into the file
+				if getLimitInt > total {
+					getLimitInt = total
+				}
 
-				params.Add("offset", strconv.Itoa(offset))
+				params.Set("metadata", "false") // metadata makes calls slow, we do not need it for the actual data gets
 
-				resp, requestErr = utilities.MakeAPIRequests("get", "workflow_sessions", id, params.Encode(), nil)
-				utilities.CheckError(requestErr)
+				table := sessionTable
+				if humanReadable {
+					table = humanSessionTable
+				}
+				output, err = utilities.NewExporter(outputLoc, configs.GetOutputFormats(), table)
+				if err != nil {
+					return err
+				}
 
-				err := json.Unmarshal(resp, &sessions)
-				utilities.CheckError(err)
+				checkpoint = utilities.NewCheckpoint("sessions get", configs.GetCurrentEnvironment(), outputLoc)
+				checkpoint.Formats = output.Formats
+				checkpoint.Params = params.Encode()
+				checkpoint.GetLimit = getLimitInt
+				checkpoint.Flags["id"] = id
+				checkpoint.Flags["limit"] = strconv.Itoa(limitInt)
+				checkpoint.Flags["days"] = dayString
+				checkpoint.Flags["created_after"] = compareDate.Format(time.RFC3339)
+				checkpoint.Flags["human_readable"] = strconv.FormatBool(humanReadable)
+
+				if err := checkpoint.Advance(0, "", output); err != nil {
+					output.Close()
+					return err
+				}
+			}
+			defer output.Close() // leaves valid JSON files, even when the export stops early
+
+			pager.Limit = limitInt
+			pager.GetLimit = getLimitInt
+			pager.Offset = startOffset
+			pager.Concurrency = concurrency
+
+			// profiles, users and workflows are looked up once for the whole export, not once per session
+			var names *nameResolver
+			if humanReadable {
+				names, err = newNameResolver(cmd.Context(), pager.Client, concurrency)
+				if err != nil {
+					return err
+				}
+			}
 
-				// err = json.Unmarshal(resp, &respMetaData)
-				// utilities.CheckError(err)
+			bar := progressbar.Default(int64(getLimitInt)) // set progress to number of profile types found
+			bar.Set(startOffset)
 
-				if (offset + limitInt) >= getLimitInt {
+			err = pager.Pages(cmd.Context(), func(page utilities.Page[SessionJsonFileData]) error {
+				if page.Last {
 					bar.Set(getLimitInt)
-					lastLoop = true
 				} else {
-					bar.Add(limitInt) // increment progress
+					bar.Add(len(page.Records)) // increment progress
 				}
 
-				for _, rec := range sessions.Sessions {
-
-					/*
-						if humanReadable {
-							// Currently Not In Use
-							// TODO: Build a map of previously found profiles and Users to reduce the number of requests made..
-							// TODO: Re-define structs to add these values
-							// TODO: Re-define how CSV gets its headers to allow for these values dynamically
-							var profile ProfileResponse
-							profileResp, profileRequestErr := utilities.MakeAPIRequests("get", "profiles", rec.ProfileID, "exclude_attributes=true", nil)
-							utilities.CheckError(profileRequestErr)
-
-							profileUnmarshalErr := json.Unmarshal(profileResp, &profile)
-							utilities.CheckError(profileUnmarshalErr)
-
-							// profile.Name
-
-							var user UserResponse
-							userResp, userRequestErr := utilities.MakeAPIRequests("get", "profiles", rec.ProfileID, "exclude_attributes=true", nil)
-							utilities.CheckError(userRequestErr)
-
-							userUnmarshalErr := json.Unmarshal(userResp, &user)
-							utilities.CheckError(userUnmarshalErr)
-
-							// user.User.Login
-
-						}
-					*/
-
+				var sessions []SessionJsonFileData
+				for _, rec := range page.Records {
 					if dayString != "" {
-						t := time.Now().AddDate(0, 0, (days * -1))
-						compareDate := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) // zeros out the day
-
 						createdAtTime, dateErr := time.Parse(time.RFC3339, rec.CreatedAt)
-						utilities.CheckError(dateErr)
+						if dateErr != nil {
+							return dateErr
+						}
 
-						if createdAtTime.After(compareDate) {
-							// fmt.Println("after")
-							finalSessions.Sessions = append(finalSessions.Sessions, rec)
+						if !createdAtTime.After(compareDate) {
+							continue // only store the sessions from the last x days
 						}
-					} else {
-						finalSessions.Sessions = append(finalSessions.Sessions, rec)
+					}
+					sessions = append(sessions, rec)
+				}
+
+				if names != nil {
+					if err := names.resolve(cmd.Context(), sessions); err != nil {
+						return err
+					}
+				}
+
+				for _, rec := range sessions {
+					if err := output.Write(rec); err != nil {
+						return err
 					}
 				}
 
-				printJsonToFile(outputLoc+".json", finalSessions, lastLoop)
+				return checkpoint.Advance(page.NextOffset, "", output)
+			})
+			if err != nil {
+				return checkpoint.Failed(err)
 			}
 
-			endSessionsJsonFile(outputLoc + ".json")
+			if err := output.Finish(); err != nil {
+				return err
+			}
 
-			convertJSONToCSV(outputLoc+".json", outputLoc+".csv")
+			if err := checkpoint.Remove(); err != nil {
+				return err
+			}
+
+			if names != nil && names.failed > 0 {
+				fmt.Fprintln(os.Stderr, "\n"+"Could not look up", names.failed, "profile(s) or user(s), their names are left empty")
+			}
 
 			fmt.Println("\n" + "Session data stored in " + outputLoc)
 
@@ -208,13 +280,17 @@ func newSessionsGetCommand() *cobra.Command {
 	cmd.Flags().StringP("uid", "u", "", "UID of a specific Workflow Session")
 	cmd.Flags().StringP("profile_id", "p", "", "Find all sessions that were run for a Profile")
 	cmd.Flags().StringP("status", "s", "", "Status of the Workflow Session")
-	cmd.Flags().StringP("workflow_id", "w", "", "ID of a specific Workflow that was run")
+	cmd.Flags().StringP("workflow_id", "w", "", "Workflow that was run (ID, UID or name)")
 	cmd.Flags().StringP("requester_id", "r", "", "Find all sessions that were run by a specific User")
 	cmd.Flags().StringP("limit", "l", strconv.Itoa(configs.GetDefaultLimitParam()), "Limit for each GET request")
 	cmd.Flags().StringP("get_limit", "g", "", "Set a Get limit for how many sessions to pull back (default is All sessions)")
 	cmd.Flags().StringP("days", "d", "", "Pull sessions from the last x days")
 	cmd.Flags().StringP("order", "o", "", "Sort the returned records in a certain fashion")
-	cmd.Flags().Bool("human_readable", false, "Setting to True adds Human Readable data to sessions (Requester's Login, Profile's Name)")
+	cmd.Flags().Bool("human_readable", false, "Adds the names of each session's workflow, profile and requester (and the requester's login). Each one is looked up once, --concurrency at a time")
+	cmd.Flags().String("concurrency", "1", "Number of pages to fetch at the same time")
+	cmd.Flags().String("resume", "", "Checkpoint file of a failed export to carry on from (stored next to the export as .checkpoint.json)")
+
+	cmd.RegisterFlagCompletionFunc("workflow_id", utilities.CompleteNames(utilities.Workflows))
 
 	return cmd
 }
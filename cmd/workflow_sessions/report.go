@@ -0,0 +1,250 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package workflow_sessions
+
+import (
+	"cmp"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// reportRow is one row of the file written by 'sessions report'. Each dimension (workflow, status, requester or
+// day) has a row for each of its values
+type reportRow struct {
+	Dimension   string  `json:"dimension"`
+	Key         string  `json:"key"`
+	Name        string  `json:"name"`
+	Sessions    int     `json:"sessions"`
+	Failed      int     `json:"failed"`
+	FailureRate float64 `json:"failure_rate"` // percentage of the sessions that failed
+}
+
+var reportRowTable = utilities.Table[reportRow]{
+	Headers: []string{"Dimension", "Key", "Name", "Sessions", "Failed", "FailureRate"},
+	Row: func(r reportRow) ([]string, map[string]string) {
+		return []string{r.Dimension, r.Key, r.Name, strconv.Itoa(r.Sessions), strconv.Itoa(r.Failed), strconv.FormatFloat(r.FailureRate, 'f', 1, 64)}, nil
+	},
+}
+
+// sessionTally counts the sessions and failures of one value of a dimension
+type sessionTally struct {
+	sessions int
+	failed   int
+}
+
+func (t sessionTally) failureRate() float64 {
+	if t.sessions == 0 {
+		return 0
+	}
+	return float64(t.failed) * 100 / float64(t.sessions)
+}
+
+func newSessionsReportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "report",
+		Short:   "Reports on the Workflow Sessions of the last x days",
+		Long:    "Pulls the workflow sessions of the last x days and counts them by workflow, status, requester and day, with the failure rate of each. Prints the workflows with the most failures and writes every count to a file in the formats set with --format",
+		Example: "nerm sessions report --days 7 | nerm sessions report --days 30 --workflow_id \"New Contractor\" --top 5",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			dayString := cmd.Flags().Lookup("days").Value.String()
+			workflow_id := cmd.Flags().Lookup("workflow_id").Value.String()
+			top := cmd.Flags().Lookup("top").Value.String()
+
+			days, err := strconv.Atoi(dayString)
+			if err != nil || days < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number for --days", dayString)
+			}
+
+			topInt, err := strconv.Atoi(top)
+			if err != nil || topInt < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number for --top", top)
+			}
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			workflow_id, err = utilities.ResolveID(cmd.Context(), client, utilities.Workflows, workflow_id)
+			if err != nil {
+				return err
+			}
+
+			workflowNames, err := utilities.ObjectNames(cmd.Context(), client, utilities.Workflows)
+			if err != nil {
+				return err
+			}
+
+			t := time.Now().AddDate(0, 0, (days * -1))
+			compareDate := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) // zeros out the day
+
+			params := url.Values{}
+			params.Add("order", "created_at DESC") // newest first, so paging can stop at the first session older than --days
+			if workflow_id != "" {
+				params.Add("workflow_id", workflow_id)
+			}
+
+			pager := utilities.NewPaginator[SessionJsonFileData](client, "workflow_sessions", "workflow_sessions", params)
+			pager.Concurrency = concurrency
+
+			byWorkflow := map[string]*sessionTally{}
+			byStatus := map[string]*sessionTally{}
+			byRequester := map[string]*sessionTally{}
+			byDay := map[string]*sessionTally{}
+			total := sessionTally{}
+
+			add := func(tallies map[string]*sessionTally, key string, failed bool) {
+				if tallies[key] == nil {
+					tallies[key] = &sessionTally{}
+				}
+				tallies[key].sessions++
+				if failed {
+					tallies[key].failed++
+				}
+			}
+
+			fmt.Println("Pulling the sessions of the last", days, "day(s)")
+			err = pager.Each(cmd.Context(), func(rec SessionJsonFileData) error {
+				createdAtTime, dateErr := time.Parse(time.RFC3339, rec.CreatedAt)
+				if dateErr != nil {
+					return dateErr
+				}
+				if !createdAtTime.After(compareDate) {
+					return utilities.ErrStopPaging
+				}
+
+				failed := strings.EqualFold(rec.Status, "failed")
+				total.sessions++
+				if failed {
+					total.failed++
+				}
+
+				add(byWorkflow, rec.WorkflowID, failed)
+				add(byStatus, rec.Status, failed)
+				add(byRequester, strings.TrimSpace(rec.RequesterType+" "+rec.RequesterID), failed)
+				add(byDay, createdAtTime.Local().Format(time.DateOnly), failed)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if total.sessions == 0 {
+				fmt.Println("No sessions were run in the last", days, "day(s)")
+				return nil
+			}
+
+			workflowName := func(id string) string {
+				if name, ok := workflowNames[id]; ok {
+					return name
+				}
+				return id
+			}
+
+			// rows of each dimension, the ones with the most failures (then sessions) first
+			reportRows := func(dimension string, tallies map[string]*sessionTally, name func(string) string) []reportRow {
+				var rows []reportRow
+				for key, tally := range tallies {
+					rows = append(rows, reportRow{Dimension: dimension, Key: key, Name: name(key), Sessions: tally.sessions, Failed: tally.failed, FailureRate: tally.failureRate()})
+				}
+				slices.SortFunc(rows, func(a, b reportRow) int {
+					return cmp.Or(cmp.Compare(b.Failed, a.Failed), cmp.Compare(b.Sessions, a.Sessions), strings.Compare(a.Name, b.Name))
+				})
+				return rows
+			}
+			same := func(key string) string { return key }
+
+			workflowRows := reportRows("workflow", byWorkflow, workflowName)
+			statusRows := reportRows("status", byStatus, same)
+			requesterRows := reportRows("requester", byRequester, same)
+			dayRows := reportRows("day", byDay, same)
+			slices.SortFunc(dayRows, func(a, b reportRow) int { return strings.Compare(a.Key, b.Key) }) // days are shown in order
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Sessions_Report" + strconv.Itoa(int(time.Now().Unix()))
+
+			output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), reportRowTable)
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
+
+			for _, rows := range [][]reportRow{workflowRows, statusRows, requesterRows, dayRows} {
+				for _, row := range rows {
+					if err := output.Write(row); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			// failure rates are shown in red once more than a tenth of the sessions fail
+			tableRows := func(rows []reportRow, limit int) [][]string {
+				var data [][]string
+				for _, row := range rows[:min(len(rows), limit)] {
+					rate := fmt.Sprintf("%.1f%%", row.FailureRate)
+					if row.FailureRate > 10 {
+						rate = color.RedString(rate)
+					}
+					data = append(data, []string{row.Name, strconv.Itoa(row.Sessions), strconv.Itoa(row.Failed), rate})
+				}
+				return data
+			}
+
+			var failingRows []reportRow
+			for _, row := range workflowRows {
+				if row.Failed > 0 {
+					failingRows = append(failingRows, row)
+				}
+			}
+
+			fmt.Println()
+			if len(failingRows) > 0 {
+				fmt.Println("Top failing workflows")
+				printReportTable("Workflow", tableRows(failingRows, topInt))
+			} else {
+				fmt.Println("No sessions failed")
+			}
+
+			fmt.Println("\n" + "By status")
+			printReportTable("Status", tableRows(statusRows, len(statusRows)))
+
+			fmt.Println("\n" + "By day")
+			printReportTable("Day", tableRows(dayRows, len(dayRows)))
+
+			fmt.Println("\n" + "Top requesters")
+			printReportTable("Requester", tableRows(requesterRows, topInt))
+
+			fmt.Printf("\nTotal of all Sessions: %d, %d failed (%.1f%%)\n", total.sessions, total.failed, total.failureRate())
+			fmt.Println("Report stored in " + outputLoc)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("days", "d", "7", "Report on the sessions of the last x days")
+	cmd.Flags().StringP("workflow_id", "w", "", "Only report on the sessions of a Workflow (ID, UID or name)")
+	cmd.Flags().String("top", "10", "Number of workflows and requesters to show in the tables. The file has all of them")
+	cmd.Flags().String("concurrency", "1", "Number of pages to fetch at the same time")
+
+	cmd.RegisterFlagCompletionFunc("workflow_id", utilities.CompleteNames(utilities.Workflows))
+
+	return cmd
+}
@@ -1,50 +1,389 @@
 /*
 Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 */
-
-// CLONED FROM GET - NEEDS TO BE UPDATED STILL
 package workflow_sessions
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
+	"nerm/cmd/configs"
 	"nerm/cmd/utilities"
 	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
+// rerunSampleSize is how many of the matched sessions are shown before asking to continue
+const rerunSampleSize = 10
+
+// rerunResult is one row of the report written by 'sessions rerun'
+type rerunResult struct {
+	ID         string `json:"id"`
+	WorkflowID string `json:"workflow_id"`
+	ProfileID  string `json:"profile_id"`
+	OldStatus  string `json:"old_status"`
+	NewID      string `json:"new_id"`
+	NewStatus  string `json:"new_status"`
+	Result     string `json:"result"` // rerun, not_found or failed
+	Error      string `json:"error,omitempty"`
+}
+
+var rerunResultTable = utilities.Table[rerunResult]{
+	Headers: []string{"ID", "WorkflowID", "ProfileID", "OldStatus", "NewID", "NewStatus", "Result", "Error"},
+	Row: func(r rerunResult) ([]string, map[string]string) {
+		return []string{r.ID, r.WorkflowID, r.ProfileID, r.OldStatus, r.NewID, r.NewStatus, r.Result, r.Error}, nil
+	},
+}
+
 func newSessionsRerunCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "Rerun",
-		Short:   "Re-runs a given workflow session, using the same data it already has",
-		Long:    "Given a list or file of IDs of sessions, the workflow sessions will be re-run (a new session with the same data is created)",
-		Example: "nerm sessions rerun -i 1234abcd-1234-abcd-5678-12345abcd5678 | nerm sessions rerun -f id_list.csv",
-		Aliases: []string{"g"},
+		Use:     "rerun [session IDs]",
+		Short:   "Re-runs Workflow Sessions, using the same data they already have",
+		Long:    "Re-runs workflow sessions by ID, from the ID column of a CSV or JSON file, or every session that matches the same filters as 'sessions get' (ie --status failed --days 7). A new session is started with the workflow, profile and attributes of each one. Shows how many sessions match and a sample of them, then asks for a typed confirmation unless --yes is set. Writes a report with the new session ID and status of each one",
+		Example: "nerm sessions rerun -i 1234abcd-1234-abcd-5678-12345abcd5678 | nerm sessions rerun -f id_list.csv | nerm sessions rerun --status failed --workflow_id \"New Contractor\" --days 2",
+		Aliases: []string{"r"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			ids := cmd.Flags().Lookup("id").Value.String()
+			file := cmd.Flags().Lookup("file").Value.String()
+			profile_id := cmd.Flags().Lookup("profile_id").Value.String()
+			status := cmd.Flags().Lookup("status").Value.String()
+			workflow_id := cmd.Flags().Lookup("workflow_id").Value.String()
+			requester_id := cmd.Flags().Lookup("requester_id").Value.String()
+			getLimit := cmd.Flags().Lookup("get_limit").Value.String()
+			dayString := cmd.Flags().Lookup("days").Value.String()
+			dryRun, _ := cmd.Flags().GetBool("dry_run")
+			yes, _ := cmd.Flags().GetBool("yes")
+
+			concurrency, err := utilities.ConcurrencyFlag(cmd.Flags().Lookup("concurrency").Value.String())
+			if err != nil {
+				return err
+			}
+
+			var sessionIDs []string
+			for _, id := range append(args, strings.Split(ids, ",")...) {
+				if id = strings.TrimSpace(id); id != "" {
+					sessionIDs = append(sessionIDs, id)
+				}
+			}
+			if file != "" {
+				fileIDs, err := readSessionIDs(file)
+				if err != nil {
+					return err
+				}
+				sessionIDs = append(sessionIDs, fileIDs...)
+			}
+
+			filtered := profile_id != "" || status != "" || workflow_id != "" || requester_id != "" || dayString != ""
+			if len(sessionIDs) > 0 && filtered {
+				return utilities.ValidationErrorf("use either session IDs or filters, not both")
+			}
+
+			var sessions []SessionJsonFileData
+			var skipped []rerunResult // sessions that are not found
+
+			if len(sessionIDs) > 0 {
+				slices.Sort(sessionIDs)
+				sessionIDs = slices.Compact(sessionIDs) // remove duplicates
+
+				fmt.Println("Pulling", len(sessionIDs), "session(s)")
+				sessions, skipped, err = pullSessions(cmd.Context(), client, sessionIDs, concurrency)
+				if err != nil {
+					return err
+				}
+			} else {
+				if !filtered {
+					return utilities.ValidationErrorf("give the sessions to re-run with IDs, --file, or at least one filter (ie --status failed)")
+				}
+
+				getLimitInt := math.MaxInt
+				if getLimit != "" {
+					getLimitInt, err = strconv.Atoi(getLimit)
+					if err != nil {
+						return utilities.ValidationErrorf("%q is not a valid number for --get_limit", getLimit)
+					}
+				}
+
+				var compareDate time.Time
+				if dayString != "" {
+					days, err := strconv.Atoi(dayString)
+					if err != nil {
+						return utilities.ValidationErrorf("%q is not a valid number for --days", dayString)
+					}
+					t := time.Now().AddDate(0, 0, (days * -1))
+					compareDate = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) // zeros out the day
+				}
+
+				workflow_id, err = utilities.ResolveID(cmd.Context(), client, utilities.Workflows, workflow_id)
+				if err != nil {
+					return err
+				}
+
+				params := url.Values{}
+				params.Add("order", "created_at DESC") // newest first, so paging can stop at the first session older than --days
+				if profile_id != "" {
+					params.Add("profile_id", profile_id)
+				}
+				if status != "" {
+					params.Add("status", status)
+				}
+				if workflow_id != "" {
+					params.Add("workflow_id", workflow_id)
+				}
+				if requester_id != "" {
+					params.Add("requester_id", requester_id)
+				}
+
+				pager := utilities.NewPaginator[SessionJsonFileData](client, "workflow_sessions", "workflow_sessions", params)
+				pager.GetLimit = getLimitInt
+				pager.Concurrency = concurrency
+
+				fmt.Println("Finding sessions")
+				err = pager.Each(cmd.Context(), func(rec SessionJsonFileData) error {
+					if dayString != "" {
+						createdAtTime, dateErr := time.Parse(time.RFC3339, rec.CreatedAt)
+						if dateErr != nil {
+							return dateErr
+						}
+						if !createdAtTime.After(compareDate) {
+							return utilities.ErrStopPaging
+						}
+					}
+
+					sessions = append(sessions, rec)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			outputLoc := configs.GetOutputFolder() + configs.GetCurrentEnvironment() + "_Sessions_Rerun" + strconv.Itoa(int(time.Now().Unix()))
+
+			// the report starts with the sessions that are skipped, and is only written once there is something to report
+			newReport := func() (*utilities.Exporter[rerunResult], error) {
+				output, err := utilities.NewExporter(outputLoc, configs.GetOutputFormats(), rerunResultTable)
+				if err != nil {
+					return nil, err
+				}
+				for _, result := range skipped {
+					if err := output.Write(result); err != nil {
+						output.Abort()
+						return nil, err
+					}
+				}
+				return output, nil
+			}
+
+			if len(sessions) == 0 {
+				if len(skipped) == 0 {
+					fmt.Println("No sessions match")
+					return nil
+				}
+				output, err := newReport()
+				if err != nil {
+					return err
+				}
+				if err := output.Finish(); err != nil {
+					return err
+				}
+				fmt.Println("No sessions to re-run. Report stored in " + outputLoc)
+				return nil
+			}
+
+			workflowNames, err := utilities.ObjectNames(cmd.Context(), client, utilities.Workflows)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println()
+			printSessionSampleTable(sessions[:min(len(sessions), rerunSampleSize)], workflowNames)
+			if len(sessions) > rerunSampleSize {
+				fmt.Println("...and", len(sessions)-rerunSampleSize, "more")
+			}
+			fmt.Println()
+
+			if dryRun {
+				fmt.Println(len(sessions), "session(s) would be re-run. Nothing was sent (--dry_run)")
+				return nil
+			}
+
+			if !yes {
+				confirmation := "rerun " + strconv.Itoa(len(sessions))
+				fmt.Fprint(os.Stderr, "You are about to re-run "+strconv.Itoa(len(sessions))+" session(s) in "+configs.GetCurrentEnvironment()+". Type '"+confirmation+"' to continue:")
+
+				r := bufio.NewReader(cmd.InOrStdin())
+				s, _ := r.ReadString('\n')
+				if strings.TrimSpace(s) != confirmation {
+					return utilities.ValidationErrorf("confirmation did not match. No sessions were re-run")
+				}
+			}
+
+			output, err := newReport()
+			if err != nil {
+				return err
+			}
+			defer output.Abort()
 
-			var resp []byte
-			var requestErr error
-			var err error
+			results := make([]rerunResult, len(sessions))
+			bar := progressbar.Default(int64(len(sessions)))
 
-			params := url.Values{}
+			rerunSession := func(ctx context.Context, i int) ([]byte, error) {
+				session := sessions[i]
+				results[i] = rerunResult{ID: session.ID, WorkflowID: session.WorkflowID, ProfileID: session.ProfileID, OldStatus: session.Status, Result: "rerun"}
 
-			id := cmd.Flags().Lookup("id").Value.String()
-			// fileLoc := cmd.Flags().Lookup("file").Value.String()
+				attributes := session.Attributes
+				if attributes == nil {
+					attributes = map[string]string{}
+				}
 
-			// make first call to get the total number of sessisions to be returned
-			resp, requestErr = utilities.MakeAPIRequests("get", "workflow_sessions", id, params.Encode(), nil)
-			utilities.CheckError(requestErr)
+				body, err := json.Marshal(map[string][]newSession{"workflow_sessions": {{WorkflowID: session.WorkflowID, ProfileID: session.ProfileID, Attributes: attributes}}})
+				if err != nil {
+					return nil, err
+				}
 
-			var sessions_result SessionResponse
+				resp, err := client.WithContext(ctx).Post("workflow_sessions", "", body)
+				if err != nil && ctx.Err() == nil {
+					results[i].Result, results[i].Error = "failed", utilities.RowError(err)
+					return nil, nil
+				}
+				return resp, err
+			}
 
-			err = json.Unmarshal(resp, &sessions_result)
-			utilities.CheckError(err)
+			failed := 0
+			handleResult := func(i int, resp []byte) (bool, error) {
+				bar.Add(1)
+
+				if results[i].Result == "rerun" {
+					var created SessionResponse
+					if err := json.Unmarshal(resp, &created); err != nil {
+						return false, fmt.Errorf("can not unmarshal workflow sessions: %w", err)
+					}
+					if len(created.Sessions) > 0 && created.Sessions[0].ID != "" {
+						results[i].NewID, results[i].NewStatus = created.Sessions[0].ID, created.Sessions[0].Status
+					} else {
+						results[i].Result, results[i].Error = "failed", "the API did not return a session for this row"
+					}
+				}
+
+				if results[i].Result == "failed" {
+					failed++
+				}
+				return true, output.Write(results[i])
+			}
+
+			if err := utilities.FetchPages(cmd.Context(), concurrency, 0, len(sessions), 1, rerunSession, handleResult); err != nil {
+				return err
+			}
+
+			if err := output.Finish(); err != nil {
+				return err
+			}
+
+			fmt.Println("\n"+"Re-ran", len(sessions)-failed, "session(s). Report stored in "+outputLoc)
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d session(s) could not be re-run. See %s for the errors", failed, len(sessions), outputLoc)
+			}
 
 			return nil
 		},
 	}
-	cmd.Flags().StringP("id", "i", "", "ID of a specific Workflow Session")
-	cmd.Flags().StringP("file", "f", "", "CSV File that contains workflow sessions IDs")
+
+	cmd.Flags().StringP("id", "i", "", "ID of the session(s) to re-run. Use more than one with commas")
+	cmd.Flags().StringP("file", "f", "", "CSV, TSV, JSON or JSON Lines file with an ID column of the sessions to re-run")
+	cmd.Flags().StringP("profile_id", "p", "", "Re-run the sessions that were run for a Profile")
+	cmd.Flags().StringP("status", "s", "", "Re-run the sessions with this status (ie failed)")
+	cmd.Flags().StringP("workflow_id", "w", "", "Re-run the sessions of a Workflow (ID, UID or name)")
+	cmd.Flags().StringP("requester_id", "r", "", "Re-run the sessions that were run by a specific User")
+	cmd.Flags().StringP("get_limit", "g", "", "Re-run at most this many of the matching sessions, newest first")
+	cmd.Flags().StringP("days", "d", "", "Re-run the matching sessions from the last x days")
+	cmd.Flags().Bool("dry_run", false, "Show the sessions that would be re-run without re-running them")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().String("concurrency", "1", "Number of sessions to pull or re-run at the same time")
+
+	cmd.RegisterFlagCompletionFunc("workflow_id", utilities.CompleteNames(utilities.Workflows))
+
+	cmd.MarkFlagsMutuallyExclusive("dry_run", "yes")
 
 	return cmd
 }
+
+// readSessionIDs reads the ID column of an input file, ie an export from 'sessions get'
+func readSessionIDs(fileLoc string) ([]string, error) {
+	columns, rows, err := utilities.ReadInputFile(fileLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	idColumn := ""
+	for _, column := range columns {
+		if strings.EqualFold(column, "id") {
+			idColumn = column
+		}
+	}
+	if idColumn == "" {
+		return nil, utilities.ValidationErrorf("%s needs an ID column", fileLoc)
+	}
+
+	var ids []string
+	for _, row := range rows {
+		if id := strings.TrimSpace(row.Values[idColumn]); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// pullSessions gets the session of each ID. Sessions that do not exist are returned as not_found results
+func pullSessions(ctx context.Context, client *utilities.Client, ids []string, concurrency int) ([]SessionJsonFileData, []rerunResult, error) {
+	var sessions []SessionJsonFileData
+	var notFound []rerunResult
+
+	bar := progressbar.Default(int64(len(ids)))
+
+	fetch := func(ctx context.Context, i int) ([]byte, error) {
+		resp, err := client.WithContext(ctx).Get("workflow_sessions", ids[i], "")
+		if utilities.IsNotFound(err) {
+			return nil, nil
+		}
+		return resp, err
+	}
+
+	handle := func(i int, resp []byte) (bool, error) {
+		bar.Add(1)
+
+		var result SessionResponse
+		if len(resp) > 0 {
+			if err := json.Unmarshal(resp, &result); err != nil {
+				return false, fmt.Errorf("can not unmarshal workflow sessions: %w", err)
+			}
+		}
+
+		for _, session := range result.Sessions {
+			if session.ID == ids[i] {
+				sessions = append(sessions, session)
+				return true, nil
+			}
+		}
+
+		notFound = append(notFound, rerunResult{ID: ids[i], Result: "not_found", Error: "no workflow session has this ID"})
+		return true, nil
+	}
+
+	err := utilities.FetchPages(ctx, concurrency, 0, len(ids), 1, fetch, handle)
+	return sessions, notFound, err
+}
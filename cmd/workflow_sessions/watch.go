@@ -0,0 +1,231 @@
+/*
+Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
+*/
+package workflow_sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"nerm/cmd/configs"
+	"nerm/cmd/utilities"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsWatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "watch",
+		Short:   "Watches Workflow Sessions as they run",
+		Long:    "Polls the newest workflow sessions (or one session with --id) and shows them in a table that updates as sessions start and change status, or prints each new or changed session as a JSON line with --json. Use --until-status to stop once a session reaches a status, ie to wait for a workflow to finish in a script. When watching with filters, only sessions that start or change after the watch begins are checked against --until-status",
+		Example: "nerm sessions watch --workflow_id \"New Contractor\" | nerm sessions watch --status failed --json | nerm sessions watch -i 1234abcd-1234-abcd-5678-12345abcd5678 --until-status completed,failed --timeout 600",
+		Aliases: []string{"w"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, clientErr := utilities.ClientFromContext(cmd.Context())
+			if clientErr != nil {
+				return clientErr
+			}
+
+			id := cmd.Flags().Lookup("id").Value.String()
+			profile_id := cmd.Flags().Lookup("profile_id").Value.String()
+			status := cmd.Flags().Lookup("status").Value.String()
+			workflow_id := cmd.Flags().Lookup("workflow_id").Value.String()
+			requester_id := cmd.Flags().Lookup("requester_id").Value.String()
+			limit := cmd.Flags().Lookup("limit").Value.String()
+			interval := cmd.Flags().Lookup("interval").Value.String()
+			timeout := cmd.Flags().Lookup("timeout").Value.String()
+			untilStatus := cmd.Flags().Lookup("until-status").Value.String()
+			jsonLines, _ := cmd.Flags().GetBool("json")
+
+			limitInt, err := strconv.Atoi(limit)
+			if err != nil || limitInt < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number for --limit", limit)
+			}
+			if limitInt > 100 {
+				fmt.Fprintln(os.Stderr, "Limit can not be over 100") // stdout is kept for the sessions, ie with --json
+				limitInt = 100
+			}
+
+			intervalInt, err := strconv.Atoi(interval)
+			if err != nil || intervalInt < 1 {
+				return utilities.ValidationErrorf("%q is not a valid number of seconds for --interval", interval)
+			}
+
+			var deadline <-chan time.Time
+			if timeout != "" {
+				timeoutInt, err := strconv.Atoi(timeout)
+				if err != nil || timeoutInt < 1 {
+					return utilities.ValidationErrorf("%q is not a valid number of seconds for --timeout", timeout)
+				}
+				deadline = time.After(time.Duration(timeoutInt) * time.Second)
+			}
+
+			var untilStatuses []string
+			for _, value := range strings.Split(untilStatus, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					untilStatuses = append(untilStatuses, strings.ToLower(value))
+				}
+			}
+
+			workflow_id, err = utilities.ResolveID(cmd.Context(), client, utilities.Workflows, workflow_id)
+			if err != nil {
+				return err
+			}
+
+			params := url.Values{}
+			params.Add("order", "created_at DESC") // newest first, so each poll only needs the first page
+			params.Add("limit", strconv.Itoa(limitInt))
+			params.Add("offset", "0")
+			if profile_id != "" {
+				params.Add("profile_id", profile_id)
+			}
+			if status != "" {
+				params.Add("status", status)
+			}
+			if workflow_id != "" {
+				params.Add("workflow_id", workflow_id)
+			}
+			if requester_id != "" {
+				params.Add("requester_id", requester_id)
+			}
+			if id != "" {
+				params = url.Values{}
+			}
+
+			var workflowNames map[string]string
+			if !jsonLines {
+				workflowNames, err = utilities.ObjectNames(cmd.Context(), client, utilities.Workflows)
+				if err != nil {
+					return err
+				}
+			}
+
+			// the table is drawn over itself on a terminal, and printed again below the last one otherwise
+			redraw := isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+
+			seen := map[string]SessionJsonFileData{}
+			first := true
+
+			for {
+				resp, requestErr := client.Get("workflow_sessions", id, params.Encode())
+				if requestErr != nil {
+					if cmd.Context().Err() != nil {
+						fmt.Fprintln(os.Stderr, "Stopped watching")
+						return nil
+					}
+					switch utilities.ExitCode(requestErr) {
+					case utilities.ExitNetwork, utilities.ExitServer, utilities.ExitRateLimited:
+						// keep watching through a blip, the next poll will catch up
+						fmt.Fprintln(os.Stderr, "Poll failed, trying again in "+interval+"s:", requestErr)
+					default:
+						return requestErr
+					}
+				} else {
+					var sessions SessionResponse
+					if err := json.Unmarshal(resp, &sessions); err != nil {
+						return fmt.Errorf("can not unmarshal workflow sessions: %w", err)
+					}
+
+					var changed []SessionJsonFileData
+					var finished *SessionJsonFileData
+					for i := len(sessions.Sessions) - 1; i >= 0; i-- { // oldest first, so JSON lines are in the order sessions started
+						session := sessions.Sessions[i]
+						if id != "" && session.ID != id {
+							continue
+						}
+
+						previous, ok := seen[session.ID]
+						if ok && previous.Status == session.Status && previous.UpdatedAt == session.UpdatedAt {
+							continue
+						}
+						seen[session.ID] = session
+						changed = append(changed, session)
+
+						// sessions that already had the status when the watch began do not count, unless one was asked for by ID
+						if (!first || id != "") && slices.Contains(untilStatuses, strings.ToLower(session.Status)) {
+							finished = &session
+						}
+					}
+
+					if jsonLines {
+						for _, session := range changed {
+							line, err := json.Marshal(session)
+							if err != nil {
+								return err
+							}
+							fmt.Println(string(line))
+						}
+					} else if first || len(changed) > 0 {
+						if redraw {
+							fmt.Print("\033[H\033[2J") // clear the terminal
+						} else if !first {
+							fmt.Println()
+						}
+						fmt.Println("Watching sessions in " + configs.GetCurrentEnvironment() + " every " + interval + "s (Ctrl+C to stop). Last change at " + time.Now().Format(time.TimeOnly))
+						fmt.Println()
+						printSessionSampleTable(latestSessions(seen, limitInt), workflowNames)
+					}
+
+					if finished != nil {
+						fmt.Fprintln(os.Stderr, "Session "+finished.ID+" reached status "+finished.Status)
+						return nil
+					}
+					first = false
+				}
+
+				select {
+				case <-cmd.Context().Done():
+					fmt.Fprintln(os.Stderr, "Stopped watching")
+					return nil
+				case <-deadline:
+					if len(untilStatuses) > 0 {
+						return fmt.Errorf("no session reached status %s within %ss", untilStatus, timeout)
+					}
+					return nil
+				case <-time.After(time.Duration(intervalInt) * time.Second):
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringP("id", "i", "", "Watch a specific Workflow Session")
+	cmd.Flags().StringP("profile_id", "p", "", "Watch the sessions that run for a Profile")
+	cmd.Flags().StringP("status", "s", "", "Only watch sessions with this status (ie failed)")
+	cmd.Flags().StringP("workflow_id", "w", "", "Only watch the sessions of a Workflow (ID, UID or name)")
+	cmd.Flags().StringP("requester_id", "r", "", "Only watch the sessions run by a specific User")
+	cmd.Flags().StringP("limit", "l", "25", "Number of the newest sessions to check with each poll, and to show in the table (100 at most)")
+	cmd.Flags().String("interval", "5", "Seconds to wait between polls")
+	cmd.Flags().String("timeout", "", "Stop after this many seconds. With --until-status, the command fails if no session reached the status in time")
+	cmd.Flags().String("until-status", "", "Stop once a session reaches one of these statuses, ie completed,failed")
+	cmd.Flags().Bool("json", false, "Print each new or changed session as a JSON line instead of a table")
+
+	cmd.RegisterFlagCompletionFunc("workflow_id", utilities.CompleteNames(utilities.Workflows))
+
+	cmd.MarkFlagsMutuallyExclusive("id", "workflow_id")
+	cmd.MarkFlagsMutuallyExclusive("id", "status")
+	cmd.MarkFlagsMutuallyExclusive("id", "profile_id")
+	cmd.MarkFlagsMutuallyExclusive("id", "requester_id")
+
+	return cmd
+}
+
+// latestSessions returns the newest sessions that have been seen, newest first
+func latestSessions(seen map[string]SessionJsonFileData, limit int) []SessionJsonFileData {
+	sessions := make([]SessionJsonFileData, 0, len(seen))
+	for _, session := range seen {
+		sessions = append(sessions, session)
+	}
+	slices.SortFunc(sessions, func(a, b SessionJsonFileData) int {
+		if c := strings.Compare(b.CreatedAt, a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return sessions[:min(len(sessions), limit)]
+}
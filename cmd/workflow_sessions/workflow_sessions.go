@@ -4,14 +4,11 @@ Copyright © 2024 Zachary Tarantino-Woolson <zachary.tarantino@sailpoint.com>
 package workflow_sessions
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
 	"nerm/cmd/utilities"
-	"os"
 	"slices"
-	"strings"
 
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
 )
 
@@ -24,11 +21,15 @@ type ProfileTypeResponse struct {
 	} `json:"profile_types"`
 }
 
+// ProfileResponse is the response of profiles/<id>, which can hold the profile on its own or in a list
 type ProfileResponse struct {
-	Profile struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
-	} `json:"profile"`
+	Profile  ProfileName   `json:"profile"`
+	Profiles []ProfileName `json:"profiles"`
+}
+
+type ProfileName struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type ResponseMetaData struct {
@@ -41,18 +42,7 @@ type ResponseMetaData struct {
 }
 
 type SessionResponse struct { // full response with header
-	Sessions []struct {
-		ID            string            `json:"id"`
-		UID           string            `json:"uid"`
-		WorkflowID    string            `json:"workflow_id"`
-		RequesterType string            `json:"requester_type"`
-		RequesterID   string            `json:"requester_id"`
-		ProfileID     string            `json:"profile_id"`
-		Status        string            `json:"status"`
-		UpdatedAt     string            `json:"updated_at"`
-		CreatedAt     string            `json:"created_at"`
-		Attributes    map[string]string `json:"attributes"`
-	} `json:"workflow_sessions"`
+	Sessions []SessionJsonFileData `json:"workflow_sessions"`
 }
 
 type SessionJsonFileData struct { // individual sessions
@@ -66,16 +56,33 @@ type SessionJsonFileData struct { // individual sessions
 	UpdatedAt     string            `json:"updated_at"`
 	CreatedAt     string            `json:"created_at"`
 	Attributes    map[string]string `json:"attributes"`
+
+	// set with --human_readable
+	WorkflowName   string `json:"workflow_name,omitempty"`
+	ProfileName    string `json:"profile_name,omitempty"`
+	RequesterName  string `json:"requester_name,omitempty"`
+	RequesterLogin string `json:"requester_login,omitempty"`
 }
 
+// newSession is a workflow session as it is sent to the API to start one
+type newSession struct {
+	WorkflowID string            `json:"workflow_id"`
+	ProfileID  string            `json:"profile_id,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// UserResponse is the response of users/<id>, which can hold the user on its own or in a list
 type UserResponse struct {
-	User struct {
-		ID    string `json:"id"`
-		Type  string `json:"type"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-		Login string `json:"login"`
-	} `json:"user"`
+	User  UserName   `json:"user"`
+	Users []UserName `json:"users"`
+}
+
+type UserName struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Login string `json:"login"`
 }
 
 func NewWorkflowSessionsCommand() *cobra.Command {
@@ -94,103 +101,60 @@ func NewWorkflowSessionsCommand() *cobra.Command {
 		newSessionsGetCommand(),
 		newSessionsCreateCommand(),
 		newSessionsRerunCommand(),
+		newSessionsWatchCommand(),
+		newSessionsReportCommand(),
 		newSessionsJSONtoCSVCommand(),
 	)
 
 	return cmd
 }
 
-func createSessionsJsonFile(fileLoc string) {
-
-	file, _ := os.OpenFile(fileLoc, os.O_CREATE|os.O_TRUNC, os.ModePerm)
-	defer file.Close()
-	// file.WriteString(strings.Trim("{\"profiles\":[", "\""))
-	file.WriteString(strings.Trim("[", "\""))
-	defer file.Close()
+// sessionTable flattens sessions for the csv, tsv, xlsx and parquet exports. Each attribute gets its own column
+var sessionTable = utilities.Table[SessionJsonFileData]{
+	Headers: []string{"ID", "UID", "WorkflowID", "RequesterType", "RequesterID", "ProfileID", "Status", "UpdatedAt", "CreatedAt"},
+	Row: func(r SessionJsonFileData) ([]string, map[string]string) {
+		return []string{r.ID, r.UID, r.WorkflowID, r.RequesterType, r.RequesterID, r.ProfileID, r.Status, r.UpdatedAt, r.CreatedAt}, r.Attributes
+	},
 }
 
-func endSessionsJsonFile(fileLoc string) {
-
-	file, _ := os.OpenFile(fileLoc, os.O_APPEND, os.ModePerm)
-	defer file.Close()
-	file.WriteString(strings.Trim("]", "\""))
-	defer file.Close()
+// humanSessionTable is sessionTable with the names added by --human_readable
+var humanSessionTable = utilities.Table[SessionJsonFileData]{
+	Headers: append(slices.Clone(sessionTable.Headers), "WorkflowName", "ProfileName", "RequesterName", "RequesterLogin"),
+	Row: func(r SessionJsonFileData) ([]string, map[string]string) {
+		row, attributes := sessionTable.Row(r)
+		return append(row, r.WorkflowName, r.ProfileName, r.RequesterName, r.RequesterLogin), attributes
+	},
 }
 
-func printJsonToFile(fileLoc string, jsonData SessionResponse, lastLoop bool) {
+func printSessionSampleTable(sessions []SessionJsonFileData, workflowNames map[string]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	file, _ := os.OpenFile(fileLoc, os.O_APPEND|os.O_CREATE, os.ModePerm)
-	defer file.Close()
-	encoder := json.NewEncoder(file)
+	tbl := table.New("ID", "Workflow", "Profile", "Status", "Created At")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for i, rec := range jsonData.Sessions {
-		encoder.Encode(rec)
-
-		// fmt.Println(len(jsonData.Sessions), i, i+1)
-		if !lastLoop {
-			file.WriteString(strings.Trim(",", "\""))
-		} else if (lastLoop) && ((i + 1) != len(jsonData.Sessions)) {
-			file.WriteString(strings.Trim(",", "\""))
-			// fmt.Println("in if", (i+1) != len(jsonData.Sessions))
+	for _, session := range sessions {
+		workflow := session.WorkflowID
+		if name, ok := workflowNames[workflow]; ok {
+			workflow = name
 		}
+		tbl.AddRow(session.ID, workflow, session.ProfileID, session.Status, session.CreatedAt)
 	}
-	// encoder := json.NewEncoder(file)
-	// encoder.Encode(jsonData)
-	defer file.Close()
-}
-
-func convertJSONToCSV(source string, destination string) error {
-	var keys []string
 
-	fmt.Println("Destination:", destination)
-
-	// Read the JSON file into the struct array
-	sourceFile, err := os.Open(source)
-	utilities.CheckError(err)
+	tbl.Print()
+}
 
-	defer sourceFile.Close()
+// printReportTable prints one breakdown of 'sessions report', ie by workflow or by day
+func printReportTable(name string, data [][]string) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	var sessionData []SessionJsonFileData
-	if err := json.NewDecoder(sourceFile).Decode(&sessionData); err != nil {
-		return err
-	}
+	tbl := table.New(name, "Sessions", "Failed", "Failure Rate")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	for _, r := range sessionData { // attribute keys
-		for k := range r.Attributes {
-			keys = append(keys, k)
-		}
+	for _, row := range data {
+		tbl.AddRow(row[0], row[1], row[2], row[3])
 	}
 
-	slices.Sort(keys)           // sort a-z
-	keys = slices.Compact(keys) // remove duplicates
-
-	// Create a new file to store CSV data
-	outputFile, err := os.Create(destination)
-	utilities.CheckError(err)
-
-	defer outputFile.Close()
-
-	// Write the header of the CSV file and the successive rows by iterating through the JSON struct array
-	writer := csv.NewWriter(outputFile)
-	defer writer.Flush()
-
-	header := []string{"ID", "UID", "WorkflowID", "RequesterType", "RequesterID", "ProfileID", "Status", "UpdatedAt", "CreatedAt"}
-	// for _, k := range keys {
-	// 	header = append(header, k)
-	// }
-	header = append(header, keys...) // attribute keys
-	err = writer.Write(header)
-	utilities.CheckError(err)
-
-	for _, r := range sessionData {
-		var csvRow []string
-		csvRow = append(csvRow, r.ID, r.UID, r.WorkflowID, r.RequesterType, r.RequesterID, r.ProfileID, r.Status, r.UpdatedAt, r.CreatedAt)
-
-		for j := 9; j < len(header); j++ {
-			csvRow = append(csvRow, r.Attributes[header[j]])
-		}
-		err = writer.Write(csvRow)
-		utilities.CheckError(err)
-	}
-	return nil
+	tbl.Print()
 }
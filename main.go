@@ -4,11 +4,16 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"nerm/cmd/configs"
 	"nerm/cmd/root"
+	"nerm/cmd/utilities"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -22,13 +27,22 @@ func init() {
 }
 
 func main() {
+	// Ctrl+C cancels the context instead of killing the process, so commands can close their files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	// PrintMemUsage()
-	_ = rootCmd.Execute()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 
 	// PrintMemUsage()
 	if save_error := configs.SaveConfig(); save_error != nil {
 		log.Print("Issue saving config file", "error", save_error)
 	}
+
+	if err != nil {
+		utilities.PrintErrorSummary(os.Stderr, err)
+		os.Exit(utilities.ExitCode(err))
+	}
 }
 
 func PrintMemUsage() {